@@ -0,0 +1,139 @@
+package utils
+
+import "fmt"
+
+// OperationKind is the kind of a declarative Operation
+type OperationKind int
+
+const (
+	// RenameOp renames a field
+	RenameOp OperationKind = iota
+	// MoveOp moves a field (or a struct) to another path
+	MoveOp
+	// DropOp drops a field
+	DropOp
+	// SetDefaultOp sets a default value for a field
+	SetDefaultOp
+	// ScaleOp multiplies a numeric field by a factor
+	ScaleOp
+	// WrapOp wraps a field in a slice of one element
+	WrapOp
+	// UnwrapOp takes the only element of a slice
+	UnwrapOp
+)
+
+// Operation is a declarative change of a field between a version and the one before.
+// Paths are dotted field paths as the keys of CustomMigration, they follow pointers but can't
+// address the elements of slices and maps.
+//
+// Operations are listed in the Ops of the ConfigVersion they migrate up to,
+// their inverses are applied migrating down
+type Operation struct {
+	Kind OperationKind
+	// From is the path in the version before (the dropped path for DropOp)
+	From string
+	// To is the path in the new version (the defaulted path for SetDefaultOp)
+	To string
+	// Value is the default value of SetDefaultOp and the float64 factor of ScaleOp
+	Value any
+}
+
+// Rename returns the operation that renames the field [from] of the version before to [to]
+func Rename(from, to string) Operation {
+	return Operation{Kind: RenameOp, From: from, To: to}
+}
+
+// Move returns the operation that moves the field (or struct) [from] of the version before to [to]
+func Move(from, to string) Operation {
+	return Operation{Kind: MoveOp, From: from, To: to}
+}
+
+// Drop returns the operation that drops the field [path] of the version before
+func Drop(path string) Operation {
+	return Operation{Kind: DropOp, From: path}
+}
+
+// SetDefault returns the operation that sets [value] in the field [path] when it is not migrated
+// from the version before (it has the zero value)
+func SetDefault(path string, value any) Operation {
+	return Operation{Kind: SetDefaultOp, To: path, Value: value}
+}
+
+// Scale returns the operation that multiplies the numeric field [path] by [factor],
+// integer fields are rounded to the nearest integer
+func Scale(path string, factor float64) Operation {
+	return Operation{Kind: ScaleOp, From: path, To: path, Value: factor}
+}
+
+// Wrap returns the operation that wraps the field [from] of the version before
+// in the slice [to] of one element (no elements if the field has the zero value)
+func Wrap(from, to string) Operation {
+	return Operation{Kind: WrapOp, From: from, To: to}
+}
+
+// Unwrap returns the operation that sets [to] to the only element of the slice [from]
+// of the version before, it fails if the slice has more than one element
+func Unwrap(from, to string) Operation {
+	return Operation{Kind: UnwrapOp, From: from, To: to}
+}
+
+// Path returns the path changed by the operation in the destination version
+func (op Operation) Path() string {
+	if op.Kind == DropOp {
+		return op.From
+	}
+	return op.To
+}
+
+// Inverse returns the operation that undoes [op] migrating down,
+// false if there is nothing to apply (a dropped field can't be restored).
+// The inverse of SetDefault drops the field, it must not be applied when the field
+// exists also in the version before, where it keeps its value
+func (op Operation) Inverse() (Operation, bool) {
+	switch op.Kind {
+	case RenameOp, MoveOp:
+		return Operation{Kind: op.Kind, From: op.To, To: op.From}, true
+	case SetDefaultOp:
+		return Drop(op.To), true
+	case ScaleOp:
+		if factor, ok := op.Value.(float64); ok && factor != 0 {
+			return Scale(op.To, 1/factor), true
+		}
+	case WrapOp:
+		return Unwrap(op.To, op.From), true
+	case UnwrapOp:
+		return Wrap(op.To, op.From), true
+	}
+	return Operation{}, false
+}
+
+func (op Operation) String() string {
+	switch op.Kind {
+	case RenameOp:
+		return fmt.Sprintf("Rename(%s, %s)", op.From, op.To)
+	case MoveOp:
+		return fmt.Sprintf("Move(%s, %s)", op.From, op.To)
+	case DropOp:
+		return fmt.Sprintf("Drop(%s)", op.From)
+	case SetDefaultOp:
+		return fmt.Sprintf("SetDefault(%s, %v)", op.To, op.Value)
+	case ScaleOp:
+		return fmt.Sprintf("Scale(%s, %v)", op.To, op.Value)
+	case WrapOp:
+		return fmt.Sprintf("Wrap(%s, %s)", op.From, op.To)
+	case UnwrapOp:
+		return fmt.Sprintf("Unwrap(%s, %s)", op.From, op.To)
+	}
+	return fmt.Sprintf("Operation(%d)", op.Kind)
+}
+
+// InverseOperations returns the operations that undo [ops] migrating down, in reverse order
+func InverseOperations(ops []Operation) []Operation {
+	var inverse []Operation
+	for i := len(ops) - 1; i >= 0; i-- {
+		if op, ok := ops[i].Inverse(); ok {
+			inverse = append(inverse, op)
+		}
+	}
+	return inverse
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NodeMigration transforms in place the yaml tree of a config, the keys are the ones of the yaml file.
+// Node migrations don't need the Config struct of the version they migrate from, so old versions
+// can be retired from ConfigVersions while still being migratable
+type NodeMigration func(tree map[string]any) error
+
+// TreeGet returns the value of the dotted yaml [path] in [tree]
+func TreeGet(tree map[string]any, path string) (any, bool) {
+	parent, key, err := treeParent(tree, path, false)
+	if err != nil || parent == nil {
+		return nil, false
+	}
+	value, ok := parent[key]
+	return value, ok
+}
+
+// TreeSet sets the value of the dotted yaml [path] in [tree] creating the missing parents
+func TreeSet(tree map[string]any, path string, value any) error {
+	parent, key, err := treeParent(tree, path, true)
+	if err != nil {
+		return err
+	}
+	parent[key] = value
+	return nil
+}
+
+// TreeSetDefault sets the value of the dotted yaml [path] in [tree] if it is not already present
+func TreeSetDefault(tree map[string]any, path string, value any) error {
+	if _, ok := TreeGet(tree, path); ok {
+		return nil
+	}
+	return TreeSet(tree, path, value)
+}
+
+// TreeDelete removes the dotted yaml [path] from [tree], it does nothing if the path is not present
+func TreeDelete(tree map[string]any, path string) error {
+	parent, key, err := treeParent(tree, path, false)
+	if err != nil {
+		return err
+	}
+	if parent != nil {
+		delete(parent, key)
+	}
+	return nil
+}
+
+// TreeMove moves the value (or subtree) of the dotted yaml path [from] to the path [to] of [tree],
+// it does nothing if [from] is not present
+func TreeMove(tree map[string]any, from, to string) error {
+	value, ok := TreeGet(tree, from)
+	if !ok {
+		return nil
+	}
+	if err := TreeDelete(tree, from); err != nil {
+		return err
+	}
+	return TreeSet(tree, to, value)
+}
+
+// TreeRename renames the last key of the dotted yaml [path] of [tree] to [name]
+func TreeRename(tree map[string]any, path, name string) error {
+	to := name
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		to = path[:i+1] + name
+	}
+	return TreeMove(tree, path, to)
+}
+
+// treeParent returns the mapping containing the last key of [path] and the key,
+// if [create] is set the missing mappings are created otherwise a nil mapping is returned
+func treeParent(tree map[string]any, path string, create bool) (map[string]any, string, error) {
+	keys := strings.Split(path, ".")
+	current := tree
+	for i, key := range keys[:len(keys)-1] {
+		value, ok := current[key]
+		if !ok {
+			if !create {
+				return nil, "", nil
+			}
+			value = map[string]any{}
+			current[key] = value
+		}
+		next, ok := value.(map[string]any)
+		if !ok {
+			return nil, "", fmt.Errorf("%s is not a mapping", strings.Join(keys[:i+1], "."))
+		}
+		current = next
+	}
+	return current, keys[len(keys)-1], nil
+}
@@ -1,14 +1,109 @@
 package utils
 
+import "context"
+
 // CustomMigration it's a map with key the field of the config and value the associated function
 // that takes a version of yaml and returns the value for the field
 type CustomMigration map[string]func(c any) any
 
+// CustomMigrationE is like CustomMigration with functions that can fail,
+// an error stops the migration
+type CustomMigrationE map[string]func(c any) (any, error)
+
+// Checked returns [m] as a CustomMigrationE whose functions never fail
+func (m CustomMigration) Checked() CustomMigrationE {
+	if m == nil {
+		return nil
+	}
+	checked := make(CustomMigrationE, len(m))
+	for key, f := range m {
+		f := f
+		checked[key] = func(c any) (any, error) { return f(c), nil }
+	}
+	return checked
+}
+
+// CustomMigrationContext is like CustomMigrationE with functions that receive the context
+// of the migration, see versioningyaml.MigrateUpContext
+type CustomMigrationContext map[string]func(ctx context.Context, c any) (any, error)
+
+// WithContext returns [m] as a CustomMigrationContext whose functions ignore the context
+func (m CustomMigrationE) WithContext() CustomMigrationContext {
+	if m == nil {
+		return nil
+	}
+	withContext := make(CustomMigrationContext, len(m))
+	for key, f := range m {
+		f := f
+		withContext[key] = func(ctx context.Context, c any) (any, error) { return f(c) }
+	}
+	return withContext
+}
+
+// Hook is a migration of the whole config: it receives the context of the migration,
+// the [source] config as a struct value (never a pointer, also in the later steps of a migration
+// across more versions) and a pointer to the [dest] config being built, that it can modify.
+// An error stops the migration
+type Hook func(ctx context.Context, source, dest any) error
+
 // ConfigVersion contains a Config struct and the UP and DOWN custom migrations
+//
+// A version can be retired leaving Config nil and setting Version to its number: files of that version
+// are handled as yaml trees, so the migrations from and to it must be node migrations
 type ConfigVersion struct {
 	Config Config
-	Up     CustomMigration
-	Down   CustomMigration
+	// Version is the number of a retired version without Config struct
+	Version int
+	Up      CustomMigration
+	Down    CustomMigration
+	// UpE and DownE are custom migrations that can fail, applied together with Up and Down
+	UpE   CustomMigrationE
+	DownE CustomMigrationE
+	// UpContext and DownContext are custom migrations that receive the context of the migration,
+	// applied together with Up and Down
+	UpContext   CustomMigrationContext
+	DownContext CustomMigrationContext
+	// Ops are declarative operations applied migrating up to this version,
+	// their inverses are applied migrating down together with Down
+	Ops []Operation
+	// NodeUp migrates the yaml tree of the version before to this version, it replaces Up
+	NodeUp NodeMigration
+	// NodeDown migrates the yaml tree of this version to the version before, it replaces Down
+	NodeDown NodeMigration
+	// BeforeUp and AfterUp are called migrating up to this version before the fields are migrated
+	// (dest has the zero value, the fields it sets may be overwritten) and after the custom migrations
+	// and the operations. Hooks need the Config structs of both versions
+	BeforeUp, AfterUp Hook
+	// BeforeDown and AfterDown are called migrating down from this version as BeforeUp and AfterUp
+	BeforeDown, AfterDown Hook
+}
+
+// UpMigrations returns Up, UpE and UpContext together,
+// on the keys set in more of them UpContext wins over UpE that wins over Up
+func (cv ConfigVersion) UpMigrations() CustomMigrationContext {
+	return mergeMigrations(cv.Up, cv.UpE, cv.UpContext)
+}
+
+// DownMigrations returns Down, DownE and DownContext together as UpMigrations
+func (cv ConfigVersion) DownMigrations() CustomMigrationContext {
+	return mergeMigrations(cv.Down, cv.DownE, cv.DownContext)
+}
+
+func mergeMigrations(m CustomMigration, e CustomMigrationE, c CustomMigrationContext) CustomMigrationContext {
+	if len(e) == 0 && len(c) == 0 {
+		return m.Checked().WithContext()
+	}
+	merged := make(CustomMigrationContext, len(m)+len(e)+len(c))
+	for key, f := range m.Checked().WithContext() {
+		merged[key] = f
+	}
+	for key, f := range e.WithContext() {
+		merged[key] = f
+	}
+	for key, f := range c {
+		merged[key] = f
+	}
+	return merged
 }
 
 // Config it's the interface that requires to implement a V() method that expose the version of the yaml
@@ -0,0 +1,162 @@
+package versioningyaml
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+)
+
+// Changelog returns the Markdown changelog of the versions of the default registry, see Registry.Changelog
+func Changelog() string {
+	return defaultRegistry.Changelog()
+}
+
+// Changelog compares every registered version with the one before and returns a Markdown changelog,
+// newest version first, listing for every version the yaml keys added and removed, the ones whose type
+// or short rendering changed, the custom migrations, the operations, the node migrations and the hooks
+func (r *Registry) Changelog() string {
+	cv := r.snapshot().configVersions
+	var b strings.Builder
+	b.WriteString("# Changelog\n")
+	for i := len(cv) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "\n## v%d\n\n", versionNumber(cv[i]))
+		var entries []string
+		if i == 0 {
+			entries = []string{"first version"}
+		} else {
+			entries = versionChanges(cv[i-1], cv[i])
+		}
+		if cv[i].Config == nil {
+			entries = append([]string{"retired, the Config struct was removed"}, entries...)
+		}
+		if len(entries) == 0 {
+			entries = []string{"no changes"}
+		}
+		for _, entry := range entries {
+			fmt.Fprintf(&b, "- %s\n", entry)
+		}
+	}
+	return b.String()
+}
+
+// WriteChangelog writes the Markdown changelog of the versions of the default registry to [w],
+// see Registry.WriteChangelog
+func WriteChangelog(w io.Writer) error {
+	return defaultRegistry.WriteChangelog(w)
+}
+
+// WriteChangelog validates the registered versions (see Registry.Validate) and writes their Markdown
+// changelog (see Registry.Changelog) to [w], to generate the CHANGELOG.md of a project from its registry
+func (r *Registry) WriteChangelog(w io.Writer) error {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("writing changelog: %w", err)
+	}
+	if err := r.Validate(); err != nil {
+		return wrapErr(err)
+	}
+	if _, err := io.WriteString(w, r.Changelog()); err != nil {
+		return wrapErr(err)
+	}
+	return nil
+}
+
+// schemaField is a non struct field as seen in the yaml file
+type schemaField struct {
+	typ   reflect.Type
+	short bool
+}
+
+// schemaFields returns the yaml paths of the non struct fields of the struct type [t] in order
+func schemaFields(t reflect.Type) ([]string, map[string]schemaField) {
+	paths, types := leafFields(t)
+	keys := make([]string, 0, len(paths))
+	fields := map[string]schemaField{}
+	for _, path := range paths {
+		field, _ := fieldByPath(t, path)
+		_, short := field.Tag.Lookup("short")
+		key := yamlPath(t, path)
+		keys = append(keys, key)
+		fields[key] = schemaField{typ: types[path], short: short}
+	}
+	return keys, fields
+}
+
+// versionChanges returns the changelog entries of [cur] compared to the version before [prev]
+func versionChanges(prev, cur utils.ConfigVersion) []string {
+	var entries []string
+	prevType, curType := configType(prev), configType(cur)
+	if prevType != nil && curType != nil {
+		oldKeys, oldFields := schemaFields(prevType)
+		newKeys, newFields := schemaFields(curType)
+		var added, removed []string
+		for _, key := range newKeys {
+			if _, ok := oldFields[key]; !ok {
+				added = append(added, fmt.Sprintf("`%s` (%v)", key, newFields[key].typ))
+			}
+		}
+		for _, key := range oldKeys {
+			if _, ok := newFields[key]; !ok {
+				removed = append(removed, fmt.Sprintf("`%s` (%v)", key, oldFields[key].typ))
+			}
+		}
+		if len(added) > 0 {
+			entries = append(entries, "added "+strings.Join(added, ", "))
+		}
+		if len(removed) > 0 {
+			entries = append(entries, "removed "+strings.Join(removed, ", "))
+		}
+		for _, key := range newKeys {
+			oldField, ok := oldFields[key]
+			if !ok {
+				continue
+			}
+			newField := newFields[key]
+			if oldField.typ != newField.typ {
+				entries = append(entries, fmt.Sprintf("`%s` became %v (was %v)", key, newField.typ, oldField.typ))
+			}
+			if !oldField.short && newField.short {
+				entries = append(entries, fmt.Sprintf("`%s` now rendered short JSON", key))
+			} else if oldField.short && !newField.short {
+				entries = append(entries, fmt.Sprintf("`%s` no longer rendered short JSON", key))
+			}
+		}
+	}
+
+	if keys := migrationKeys(curType, cur.UpMigrations()); len(keys) > 0 {
+		entries = append(entries, "custom Up migrations: "+strings.Join(keys, ", "))
+	}
+	if keys := migrationKeys(prevType, cur.DownMigrations()); len(keys) > 0 {
+		entries = append(entries, "custom Down migrations: "+strings.Join(keys, ", "))
+	}
+	if len(cur.Ops) > 0 {
+		ops := make([]string, len(cur.Ops))
+		for i, op := range cur.Ops {
+			ops[i] = "`" + op.String() + "`"
+		}
+		entries = append(entries, "operations: "+strings.Join(ops, ", "))
+	}
+	if cur.NodeUp != nil || cur.NodeDown != nil {
+		entries = append(entries, "node migrations on the yaml tree")
+	}
+	if cur.BeforeUp != nil || cur.AfterUp != nil || cur.BeforeDown != nil || cur.AfterDown != nil {
+		entries = append(entries, "migration hooks")
+	}
+	return entries
+}
+
+// migrationKeys returns the keys of [migration] as sorted yaml paths of [t] in code format
+func migrationKeys(t reflect.Type, migration utils.CustomMigrationContext) []string {
+	keys := make([]string, 0, len(migration))
+	for key := range migration {
+		if t != nil {
+			key = yamlPath(t, key)
+		}
+		keys = append(keys, "`"+key+"`")
+	}
+	sort.Strings(keys)
+	return keys
+}
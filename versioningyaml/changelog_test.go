@@ -0,0 +1,66 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	versions "github.com/davide-camponogara/versioningyaml/versions_test"
+)
+
+func TestChangelog(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+	want := "# Changelog\n" +
+		"\n## v3\n\n" +
+		"- added `testv3` (float32), `testv3_2` (float32)\n" +
+		"- `version` became int32 (was int)\n" +
+		"- `test` now rendered short JSON\n" +
+		"- custom Up migrations: `street.Name`, `testv3`\n" +
+		"- custom Down migrations: `city`\n" +
+		"\n## v2\n\n" +
+		"- added `test` (map[int]bool)\n" +
+		"- removed `zipcode` (int)\n" +
+		"- custom Up migrations: `city`\n" +
+		"\n## v1\n\n" +
+		"- first version\n"
+	if got := r.Changelog(); got != want {
+		t.Fatalf("got changelog:\n%s\nwant:\n%s", got, want)
+	}
+
+	r.SetConfigVersions(retiredVersions)
+	if got := r.Changelog(); !strings.Contains(got, "## v2\n\n- node migrations on the yaml tree\n") ||
+		!strings.Contains(got, "## v1\n\n- retired, the Config struct was removed\n- first version\n") {
+		t.Fatalf("unexpected changelog:\n%s", got)
+	}
+}
+
+// A project generates its CHANGELOG.md from its own registry, for example in a go:generate command
+// or a test, passing the file instead of os.Stdout
+func ExampleRegistry_WriteChangelog() {
+	r := NewRegistry(versions.ConfigVersions)
+	if err := r.WriteChangelog(os.Stdout); err != nil {
+		panic(err)
+	}
+	// Output:
+	// # Changelog
+	//
+	// ## v3
+	//
+	// - added `testv3` (float32), `testv3_2` (float32)
+	// - `version` became int32 (was int)
+	// - `test` now rendered short JSON
+	// - custom Up migrations: `street.Name`, `testv3`
+	// - custom Down migrations: `city`
+	//
+	// ## v2
+	//
+	// - added `test` (map[int]bool)
+	// - removed `zipcode` (int)
+	// - custom Up migrations: `city`
+	//
+	// ## v1
+	//
+	// - first version
+}
@@ -0,0 +1,72 @@
+package versioningyaml
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+)
+
+// Decode reads a config from [r] as a struct of the related version and returns also the version
+// using the default registry
+func Decode(r io.Reader) (utils.Config, int, error) {
+	return defaultRegistry.Decode(r)
+}
+
+// Decode reads a config from [r] as a struct of the related version and returns also the version
+func (r *Registry) Decode(reader io.Reader) (utils.Config, int, error) {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, 0, fmt.Errorf("decoding config versioned: error reading: %w", err)
+	}
+	return r.DecodeBytes(content)
+}
+
+// DecodeBytes decodes the yaml [content] as a struct of the related version and returns also the version
+// using the default registry
+func DecodeBytes(content []byte) (utils.Config, int, error) {
+	return defaultRegistry.DecodeBytes(content)
+}
+
+// DecodeBytes decodes the yaml [content] as a struct of the related version and returns also the version
+func (r *Registry) DecodeBytes(content []byte) (utils.Config, int, error) {
+	config, version, err := r.snapshot().decodeBytes(content)
+	if err != nil {
+		return nil, 0, fmt.Errorf("decoding config versioned: %w", err)
+	}
+	return config, version, nil
+}
+
+// Encode writes to [w] the yaml of the tagged [data] struct (or pointer to it)
+// using the default registry
+func Encode(w io.Writer, data utils.Config) error {
+	return defaultRegistry.Encode(w, data)
+}
+
+// Encode writes to [w] the yaml of the tagged [data] struct (or pointer to it)
+func (r *Registry) Encode(w io.Writer, data utils.Config) error {
+	yamlBytes, err := r.snapshot().marshal(data)
+	if err != nil {
+		return fmt.Errorf("encoding yaml: %w", err)
+	}
+	if _, err := w.Write(yamlBytes); err != nil {
+		return fmt.Errorf("encoding yaml: error writing: %w", err)
+	}
+	return nil
+}
+
+// Marshal returns the yaml of the tagged [data] struct (or pointer to it)
+// using the default registry
+func Marshal(data utils.Config) ([]byte, error) {
+	return defaultRegistry.Marshal(data)
+}
+
+// Marshal returns the yaml of the tagged [data] struct (or pointer to it)
+func (r *Registry) Marshal(data utils.Config) ([]byte, error) {
+	yamlBytes, err := r.snapshot().marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling yaml: %w", err)
+	}
+	return yamlBytes, nil
+}
@@ -0,0 +1,59 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	versions "github.com/davide-camponogara/versioningyaml/versions_test"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+	r.SetLongComments(versions.LongComments)
+
+	content, err := os.ReadFile("testdata/configv2.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, version, err := r.Decode(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 {
+		t.Fatalf("expected version 2, got %d", version)
+	}
+
+	var b bytes.Buffer
+	if err := r.Encode(&b, config); err != nil {
+		t.Fatal(err)
+	}
+	marshaled, err := r.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.Bytes(), marshaled) {
+		t.Fatalf("Encode and Marshal differ:\n%s\n%s", b.Bytes(), marshaled)
+	}
+	decoded, _, err := r.DecodeBytes(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.(versions.ConfigV2).City != "Rome" {
+		t.Fatalf("unexpected decoded config %#v", decoded)
+	}
+
+	v3, err := r.MigrateTo(decoded, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	marshaled, err = r.Marshal(v3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(marshaled, []byte("test: {1: true,2: false}")) {
+		t.Fatalf("short field not rendered as json:\n%s", marshaled)
+	}
+}
@@ -0,0 +1,70 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+	versions "github.com/davide-camponogara/versioningyaml/versions_test"
+)
+
+type cityKey struct{}
+
+func TestMigrateContext(t *testing.T) {
+	cv := append([]utils.ConfigVersion(nil), versions.ConfigVersions...)
+	cv[1].Up = nil
+	cv[1].UpContext = utils.CustomMigrationContext{
+		"City": func(ctx context.Context, c any) (any, error) {
+			city, _ := ctx.Value(cityKey{}).(string)
+			return city, nil
+		},
+	}
+	var cancel context.CancelFunc
+	cv[1].AfterUp = func(ctx context.Context, source, dest any) error {
+		if cancel != nil {
+			cancel()
+		}
+		return nil
+	}
+	r := NewRegistry(nil)
+	if err := r.SetConfigVersionsStrict(cv); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.WithValue(context.Background(), cityKey{}, "Turin")
+	v3, err := r.MigrateUpContext(ctx, versions.ConfigV1{Version: 1, City: "Rome"}, versions.ConfigV3{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if city := v3.(*versions.ConfigV3).City; city != "Turin" {
+		t.Fatalf("expected the city from the context, got %q", city)
+	}
+
+	// the hook of the first step cancels the migration before the second one
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	_, err = r.MigrateUpContext(ctx, versions.ConfigV1{Version: 1}, versions.ConfigV3{})
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "from version 2 to 3") {
+		t.Fatalf("expected the migration cancelled before the second step, got %v", err)
+	}
+	if _, err := r.MigrateDownContext(ctx, v3, versions.ConfigV1{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the migration cancelled, got %v", err)
+	}
+}
+
+func TestLoadConfigVersionedContext(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+	if _, version, err := r.LoadConfigVersionedContext(context.Background(), "testdata/configv1.yaml"); err != nil || version != 1 {
+		t.Fatalf("unexpected result version %d, %v", version, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := r.LoadConfigVersionedContext(ctx, "testdata/configv1.yaml"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
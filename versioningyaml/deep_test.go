@@ -0,0 +1,178 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+)
+
+type deepStreetV1 struct {
+	Name   string
+	Number int
+}
+
+type deepV1 struct {
+	Version int
+	Streets []deepStreetV1
+	ByName  map[string]deepStreetV1
+	Main    *deepStreetV1
+	Fixed   [2]deepStreetV1
+	Tags    []int
+}
+
+func (deepV1) V() int { return 1 }
+
+type deepStreetV2 struct {
+	Label  string
+	Number int64
+}
+
+type deepV2 struct {
+	Version int
+	Streets []deepStreetV2
+	ByName  map[string]*deepStreetV2
+	Main    *deepStreetV2
+	Fixed   [2]deepStreetV2
+	Tags    []int32
+}
+
+func (deepV2) V() int { return 2 }
+
+var deepVersions = []utils.ConfigVersion{
+	{Config: deepV1{}},
+	{
+		Config: deepV2{},
+		Up: utils.CustomMigration{
+			// element keys receive the source element
+			"Streets[].Label": func(c any) any { return c.(deepStreetV1).Name },
+			"ByName[].Label":  func(c any) any { return c.(deepStreetV1).Name },
+			"Fixed[].Label":   func(c any) any { return c.(deepStreetV1).Name },
+			// the other keys receive the source config
+			"Main.Label": func(c any) any { return c.(deepV1).Main.Name },
+		},
+	},
+}
+
+func TestDeepMigration(t *testing.T) {
+	r := NewRegistry(nil)
+	if err := r.SetConfigVersionsStrict(deepVersions); err != nil {
+		t.Fatal(err)
+	}
+
+	v1 := deepV1{
+		Version: 1,
+		Streets: []deepStreetV1{{"a", 1}, {"b", 2}},
+		ByName:  map[string]deepStreetV1{"c": {"c", 3}},
+		Main:    &deepStreetV1{"d", 4},
+		Fixed:   [2]deepStreetV1{{"e", 5}},
+		Tags:    []int{6, 7},
+	}
+	up, err := r.MigrateUp(v1, deepV2{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := deepV2{
+		Version: 2,
+		Streets: []deepStreetV2{{"a", 1}, {"b", 2}},
+		ByName:  map[string]*deepStreetV2{"c": {"c", 3}},
+		Main:    &deepStreetV2{"d", 4},
+		Fixed:   [2]deepStreetV2{{"e", 5}},
+		Tags:    []int32{6, 7},
+	}
+	if got := up.(*deepV2); !reflect.DeepEqual(*got, want) {
+		t.Fatalf("got %#v, want %#v", *got, want)
+	}
+
+	// nil containers stay nil
+	up, err = r.MigrateUp(deepV1{Version: 1, Main: &deepStreetV1{}}, deepV2{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := up.(*deepV2); got.Streets != nil || got.ByName != nil || got.Tags != nil || got.Main == nil {
+		t.Fatalf("unexpected config %#v", *got)
+	}
+}
+
+func TestDeepMigrationKeys(t *testing.T) {
+	cv := []utils.ConfigVersion{
+		deepVersions[0],
+		{
+			Config: deepV2{},
+			Up: utils.CustomMigration{
+				"Streets[].Nome": func(c any) any { return nil },
+				"Tags[][]":       func(c any) any { return nil },
+			},
+		},
+	}
+	err := validateVersions(cv)
+	verr, ok := err.(*ValidationError)
+	if !ok || len(verr.Problems) != 2 {
+		t.Fatalf("expected 2 problems, got %v", err)
+	}
+}
+
+type arrayV1 struct {
+	Version int
+	Values  [3]int
+}
+
+func (arrayV1) V() int { return 1 }
+
+type arrayV2 struct {
+	Version int
+	Values  [2]int
+}
+
+func (arrayV2) V() int { return 2 }
+
+func TestDeepMigrationArrayLength(t *testing.T) {
+	cv := []utils.ConfigVersion{{Config: arrayV1{}}, {Config: arrayV2{}}}
+	if err := validateVersions(cv); err == nil {
+		t.Fatal("expected the shorter array to be reported")
+	}
+
+	r := NewRegistry(cv)
+	_, err := r.MigrateUp(arrayV1{Version: 1, Values: [3]int{1, 2, 3}}, arrayV2{})
+	var convErr *ConversionError
+	if !errors.As(err, &convErr) || convErr.Path != "Values" || convErr.FromVersion != 1 {
+		t.Fatalf("expected a *ConversionError on Values, got %v", err)
+	}
+}
+
+type listNode struct {
+	Name string
+	Next *listNode
+}
+
+type listV1 struct {
+	Version int
+	Head    *listNode
+}
+
+func (listV1) V() int { return 1 }
+
+type listV2 struct {
+	Version int
+	Head    *listNode
+	Nodes   []listNode
+}
+
+func (listV2) V() int { return 2 }
+
+func TestDeepMigrationRecursiveType(t *testing.T) {
+	r := NewRegistry(nil)
+	if err := r.SetConfigVersionsStrict([]utils.ConfigVersion{{Config: listV1{}}, {Config: listV2{}}}); err != nil {
+		t.Fatal(err)
+	}
+	up, err := r.MigrateUp(listV1{Version: 1, Head: &listNode{Name: "a", Next: &listNode{Name: "b"}}}, listV2{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head := up.(*listV2).Head; head.Name != "a" || head.Next.Name != "b" || head.Next.Next != nil {
+		t.Fatalf("unexpected list %#v", head)
+	}
+}
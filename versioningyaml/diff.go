@@ -0,0 +1,148 @@
+package versioningyaml
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+)
+
+// ChangeKind is the kind of a Change
+type ChangeKind int
+
+const (
+	// Added is a field present only in the second config
+	Added ChangeKind = iota
+	// Removed is a field present only in the first config
+	Removed
+	// Changed is a field with the same type and a different value
+	Changed
+	// TypeChanged is a field with a different type
+	TypeChanged
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	case TypeChanged:
+		return "type-changed"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// MarshalText renders the kind as its name in json
+func (k ChangeKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Change is the difference of the field with dotted path Path between two configs
+type Change struct {
+	Path string     `json:"path"`
+	Kind ChangeKind `json:"kind"`
+	// OldType and NewType are the types of the field, empty if it is missing
+	OldType string `json:"oldType,omitempty"`
+	NewType string `json:"newType,omitempty"`
+	// Old and New are the values of the field, nil if it is missing
+	Old any `json:"old,omitempty"`
+	New any `json:"new,omitempty"`
+}
+
+// ConfigDiff is the list of differences between two configs, in the order of the fields
+type ConfigDiff struct {
+	Changes []Change `json:"changes"`
+}
+
+// Diff compares the values of [a] and [b] (structs or pointers to them) by path, walking them as migrating does:
+// nested structs, pointers and the elements of slices, arrays and maps ("Streets[0].Name", "Ports[http]"),
+// so a change is reported on the nested field that changed. The configs can be of different versions to see
+// what a migration changed: the values missing in one of them are added or removed, the ones with a different
+// type are type-changed
+func Diff(a, b utils.Config) ConfigDiff {
+	oldPaths, oldTypes, oldValues := diffFields(a)
+	newPaths, newTypes, newValues := diffFields(b)
+
+	var diff ConfigDiff
+	for _, path := range oldPaths {
+		newType, ok := newTypes[path]
+		switch {
+		case !ok:
+			diff.Changes = append(diff.Changes, Change{Path: path, Kind: Removed, OldType: oldTypes[path].String(), Old: oldValues[path]})
+		case newType != oldTypes[path]:
+			diff.Changes = append(diff.Changes, Change{Path: path, Kind: TypeChanged,
+				OldType: oldTypes[path].String(), NewType: newType.String(), Old: oldValues[path], New: newValues[path]})
+		case !reflect.DeepEqual(oldValues[path], newValues[path]):
+			diff.Changes = append(diff.Changes, Change{Path: path, Kind: Changed,
+				OldType: oldTypes[path].String(), NewType: newType.String(), Old: oldValues[path], New: newValues[path]})
+		}
+	}
+	for _, path := range newPaths {
+		if _, ok := oldTypes[path]; !ok {
+			diff.Changes = append(diff.Changes, Change{Path: path, Kind: Added, NewType: newTypes[path].String(), New: newValues[path]})
+		}
+	}
+	return diff
+}
+
+// diffFields returns the paths, the types and the values of the non composite values of [c], see walkValues
+func diffFields(c utils.Config) ([]string, map[string]reflect.Type, map[string]any) {
+	types := map[string]reflect.Type{}
+	values := map[string]any{}
+	v := reflect.Indirect(reflect.ValueOf(c))
+	if !v.IsValid() || v.Kind() != reflect.Struct {
+		return nil, types, values
+	}
+	var paths []string
+	walkValues(v, v.Type(), "", func(path string, t reflect.Type, value reflect.Value) {
+		paths = append(paths, path)
+		types[path] = t
+		values[path] = value.Interface()
+	})
+	return paths, types, values
+}
+
+// Empty tells if there are no differences
+func (d ConfigDiff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+// String renders the differences as text, a line for every field:
+// "+" added, "-" removed, "~" changed and "!" type-changed
+func (d ConfigDiff) String() string {
+	lines := make([]string, 0, len(d.Changes))
+	for _, c := range d.Changes {
+		switch c.Kind {
+		case Added:
+			lines = append(lines, fmt.Sprintf("+ %s (%s): %s", c.Path, c.NewType, formatValue(c.New)))
+		case Removed:
+			lines = append(lines, fmt.Sprintf("- %s (%s): %s", c.Path, c.OldType, formatValue(c.Old)))
+		case Changed:
+			lines = append(lines, fmt.Sprintf("~ %s: %s -> %s", c.Path, formatValue(c.Old), formatValue(c.New)))
+		case TypeChanged:
+			lines = append(lines, fmt.Sprintf("! %s: %s -> %s (%s -> %s)", c.Path, c.OldType, c.NewType, formatValue(c.Old), formatValue(c.New)))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// JSON renders the differences as json
+func (d ConfigDiff) JSON() ([]byte, error) {
+	if d.Changes == nil {
+		d.Changes = []Change{}
+	}
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// formatValue renders [v] quoting the strings
+func formatValue(v any) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%v", v)
+}
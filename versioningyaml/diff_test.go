@@ -0,0 +1,96 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"encoding/json"
+	"testing"
+
+	versions "github.com/davide-camponogara/versioningyaml/versions_test"
+)
+
+func TestDiff(t *testing.T) {
+	v2 := versions.ConfigV2{Version: 2, Street: versions.Street{Field1: 7, Name: "Main"}, City: "Rome", Test: map[int]bool{1: true}}
+	r := NewRegistry(versions.ConfigVersions)
+	v3, err := r.MigrateUp(v2, versions.ConfigV3{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff := Diff(v2, v3)
+	want := `! Version: int -> int32 (2 -> 3)
+~ Street.Name: "Main" -> "7 Main"
++ TestV3 (float32): 7
++ TestV3_2 (float32): 0`
+	if diff.String() != want {
+		t.Fatalf("got diff:\n%s\nwant:\n%s", diff, want)
+	}
+
+	out, err := diff.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Changes []map[string]any `json:"changes"`
+	}
+	if err := json.Unmarshal(out, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Changes) != 4 || decoded.Changes[0]["kind"] != "type-changed" || decoded.Changes[2]["path"] != "TestV3" {
+		t.Fatalf("unexpected json:\n%s", out)
+	}
+
+	// same type
+	other := v2
+	other.City = "Milan"
+	diff = Diff(&v2, other)
+	if diff.String() != `~ City: "Rome" -> "Milan"` {
+		t.Fatalf("unexpected diff:\n%s", diff)
+	}
+	if !Diff(v2, &v2).Empty() {
+		t.Fatal("expected no differences")
+	}
+	if out, _ := Diff(v2, v2).JSON(); string(out) != "{\n  \"changes\": []\n}" {
+		t.Fatalf("unexpected empty json %s", out)
+	}
+}
+
+type diffStreet struct {
+	Name   string
+	Number int
+}
+
+type diffNested struct {
+	Version int
+	Streets []diffStreet
+	ByName  map[string]*diffStreet
+	Next    *diffNested
+}
+
+func (diffNested) V() int { return 1 }
+
+func TestDiffNested(t *testing.T) {
+	a := diffNested{
+		Version: 1,
+		Streets: []diffStreet{{"Main", 1}, {"Side", 2}},
+		ByName:  map[string]*diffStreet{"home": {"Main", 1}},
+	}
+	b := diffNested{
+		Version: 1,
+		Streets: []diffStreet{{"Main", 1}, {"Side", 3}, {"New", 4}},
+		ByName:  map[string]*diffStreet{"home": {"Elm", 1}},
+	}
+	want := `~ Streets[1].Number: 2 -> 3
+~ ByName[home].Name: "Main" -> "Elm"
++ Streets[2].Name (string): "New"
++ Streets[2].Number (int): 4`
+	if diff := Diff(a, b); diff.String() != want {
+		t.Fatalf("got diff:\n%s\nwant:\n%s", diff, want)
+	}
+
+	// a cycle is walked once
+	a.Next = &a
+	if diff := Diff(a, a); !diff.Empty() {
+		t.Fatalf("expected no differences, got\n%s", diff)
+	}
+}
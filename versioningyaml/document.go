@@ -0,0 +1,221 @@
+package versioningyaml
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document is a config file loaded keeping its original yaml tree, so that it can be written back
+// after a migration preserving the comments and the keys added by hand
+type Document struct {
+	// Node is the original yaml document
+	Node *yaml.Node
+	// Config is the config decoded as struct of the version of the file
+	Config utils.Config
+	// Version is the version of the file
+	Version int
+}
+
+// LoadDocument loads a config file keeping its yaml tree using the default registry, see Registry.LoadDocument
+func LoadDocument(path string) (*Document, error) {
+	return defaultRegistry.LoadDocument(path)
+}
+
+// LoadDocument loads a config file as LoadConfigVersioned does keeping also its yaml tree
+func (r *Registry) LoadDocument(path string) (*Document, error) {
+	s := r.snapshot()
+	content, err := s.readFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading document: %w", err)
+	}
+	return s.decodeDocument(content)
+}
+
+// DecodeDocument decodes the yaml [content] keeping its yaml tree using the default registry,
+// see Registry.LoadDocument
+func DecodeDocument(content []byte) (*Document, error) {
+	return defaultRegistry.DecodeDocument(content)
+}
+
+// DecodeDocument decodes the yaml [content] as DecodeBytes does keeping also its yaml tree
+func (r *Registry) DecodeDocument(content []byte) (*Document, error) {
+	return r.snapshot().decodeDocument(content)
+}
+
+func (s *snapshot) decodeDocument(content []byte) (*Document, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("loading document: %w", err)
+	}
+	config, version, err := s.decodeBytes(content)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	var node yaml.Node
+	if err := unmarshalYAML(content, &node); err != nil {
+		return nil, wrapErr(err)
+	}
+	return &Document{Node: &node, Config: config, Version: version}, nil
+}
+
+// MarshalPreserving renders [data] as yaml preserving what possible of [doc] using the default registry,
+// see Registry.MarshalPreserving
+func MarshalPreserving(doc *Document, data utils.Config) ([]byte, error) {
+	return defaultRegistry.MarshalPreserving(doc, data)
+}
+
+// MarshalPreserving renders [data] (usually the migrated doc.Config) as yaml keeping the original
+// nodes of [doc] for every field whose value didn't change, with their comments and order.
+// Changed fields are rewritten, new fields are appended as WriteYaml would write them,
+// fields removed by the migration are dropped and keys unknown to the struct of the
+// document version are kept untouched
+func (r *Registry) MarshalPreserving(doc *Document, data utils.Config) ([]byte, error) {
+	yamlBytes, err := r.snapshot().marshalPreserving(doc, data)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling yaml: %w", err)
+	}
+	return yamlBytes, nil
+}
+
+// WriteYamlPreserving writes [data] to [path] preserving what possible of [doc] using the default registry,
+// see Registry.MarshalPreserving
+func WriteYamlPreserving(doc *Document, data utils.Config, path string, opts WriteOptions) error {
+	return defaultRegistry.WriteYamlPreserving(doc, data, path, opts)
+}
+
+// WriteYamlPreserving writes [data] to [path] as WriteYamlWithOptions does preserving what possible of [doc],
+// see Registry.MarshalPreserving
+func (r *Registry) WriteYamlPreserving(doc *Document, data utils.Config, path string, opts WriteOptions) error {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("writing yaml: %w", err)
+	}
+	s := r.snapshot()
+	yamlBytes, err := s.marshalPreserving(doc, data)
+	if err != nil {
+		return wrapErr(err)
+	}
+	if err := s.writeFile(path, yamlBytes, opts); err != nil {
+		return wrapErr(err)
+	}
+	s.logger.Info("config written", "path", path, "version", data.V())
+	return nil
+}
+
+func (s *snapshot) marshalPreserving(doc *Document, data utils.Config) ([]byte, error) {
+	if doc == nil || doc.Config == nil {
+		return s.marshal(data)
+	}
+	return s.marshalMerged(doc.Node, reflect.Indirect(reflect.ValueOf(doc.Config)).Type(), data)
+}
+
+// marshalMerged renders [data] as yaml merged with the original document node [orig]
+// decoded as [sourceType], see Registry.MarshalPreserving
+func (s *snapshot) marshalMerged(orig *yaml.Node, sourceType reflect.Type, data utils.Config) ([]byte, error) {
+	generated, err := s.generateYAMLobject(reflect.Indirect(reflect.ValueOf(data)).Interface(), 0)
+	if err != nil {
+		return nil, fmt.Errorf("error generating YAML: %w", err)
+	}
+	if orig == nil || orig.Kind != yaml.DocumentNode || len(orig.Content) == 0 || orig.Content[0].Kind != yaml.MappingNode {
+		return s.encodeNode(generated)
+	}
+
+	merged := *orig
+	merged.Content = []*yaml.Node{mergeMapping(orig.Content[0], generated, sourceType)}
+	return s.encodeNode(&merged)
+}
+
+// mergeMapping merges the original mapping node [orig] with the [generated] one.
+// [sourceType] is the type [orig] was decoded into, used to tell the keys removed by the
+// migration (known to sourceType) from the ones added by hand (unknown).
+// Maps and interfaces (yaml trees of retired versions) know every key, nil doesn't know any
+func mergeMapping(orig, generated *yaml.Node, sourceType reflect.Type) *yaml.Node {
+	merged := *orig
+	merged.Content = nil
+
+	generatedIndex := map[string]int{}
+	for i := 0; i+1 < len(generated.Content); i += 2 {
+		generatedIndex[generated.Content[i].Value] = i
+	}
+	used := map[string]bool{}
+
+	for i := 0; i+1 < len(orig.Content); i += 2 {
+		key, value := orig.Content[i], orig.Content[i+1]
+		gi, ok := generatedIndex[key.Value]
+		if !ok {
+			if !knownKey(sourceType, key.Value) {
+				merged.Content = append(merged.Content, key, value)
+			}
+			continue
+		}
+		used[key.Value] = true
+		generatedValue := generated.Content[gi+1]
+
+		switch {
+		case value.Kind == yaml.MappingNode && generatedValue.Kind == yaml.MappingNode:
+			value = mergeMapping(value, generatedValue, fieldTypeByKey(sourceType, key.Value))
+		case !sameValue(value, generatedValue):
+			rewritten := *generatedValue
+			if value.LineComment != "" {
+				rewritten.LineComment = value.LineComment
+			}
+			value = &rewritten
+		}
+		merged.Content = append(merged.Content, key, value)
+	}
+
+	// fields added by the migration
+	for i := 0; i+1 < len(generated.Content); i += 2 {
+		if !used[generated.Content[i].Value] {
+			merged.Content = append(merged.Content, generated.Content[i], generated.Content[i+1])
+		}
+	}
+	return &merged
+}
+
+// knownKey tells if [key] is the yaml key of a field of [t]
+func knownKey(t reflect.Type, key string) bool {
+	return fieldTypeByKey(t, key) != nil
+}
+
+// fieldTypeByKey returns the type of the field of [t] with yaml key [key], the type of
+// the values for maps and interfaces, nil if [t] doesn't have the field
+func fieldTypeByKey(t reflect.Type, key string) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem()
+	case reflect.Interface:
+		return t
+	case reflect.Struct:
+	default:
+		return nil
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if yamlKey(t.Field(i)) == key {
+			return t.Field(i).Type
+		}
+	}
+	return nil
+}
+
+// sameValue tells if the original node [orig] and the [generated] one represent the same value
+func sameValue(orig, generated *yaml.Node) bool {
+	var a, b interface{}
+	if err := orig.Decode(&a); err != nil {
+		return false
+	}
+	// fields tagged as short are generated as quoted json strings
+	if generated.Style == yaml.SingleQuotedStyle && isValidJSON(generated.Value) {
+		if err := yaml.Unmarshal([]byte(generated.Value), &b); err != nil {
+			return false
+		}
+	} else if err := generated.Decode(&b); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
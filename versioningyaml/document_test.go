@@ -0,0 +1,75 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"strings"
+	"testing"
+
+	versions "github.com/davide-camponogara/versioningyaml/versions_test"
+)
+
+func TestMarshalPreserving(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+	r.SetLongComments(versions.LongComments)
+
+	doc, err := r.LoadDocument("testdata/commented.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrated, err := r.MigrateUp(doc.Config, &versions.ConfigV3{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := r.MarshalPreserving(doc, migrated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(content)
+
+	for _, expected := range []string{
+		"# deployment config, edited by ops",
+		"version: 3",
+		"Field1: 12 # building number",
+		"# keep the official name",
+		"Name: 12 Main Street",
+		"# where the service runs\ncity: Rome # capital",
+		"owner: ops-team # not part of the config struct",
+		"test: {1: true, 2: false}",
+		"# test v3\ntestv3: 12",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in:\n%s", expected, out)
+		}
+	}
+	// the order of the original keys is kept, new fields are appended
+	if strings.Index(out, "owner:") > strings.Index(out, "test:") || strings.Index(out, "test:") > strings.Index(out, "testv3:") {
+		t.Errorf("unexpected order of keys:\n%s", out)
+	}
+
+	// the result is still a valid config
+	decoded, version, err := r.DecodeBytes(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 3 || decoded.(versions.ConfigV3).TestV3 != 12 {
+		t.Fatalf("unexpected decoded config %#v", decoded)
+	}
+}
+
+func TestUpgradeFilePreservingComments(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+
+	result, err := r.UpgradeFile("testdata/configv1.yaml", UpgradeOptions{DryRun: true, PreserveComments: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(result.Content)
+	// zipcode is removed in version 2
+	if strings.Contains(out, "zipcode") {
+		t.Errorf("expected zipcode to be dropped:\n%s", out)
+	}
+	if !strings.Contains(out, "# Test commento numeor 1\nstreet:") || !strings.Contains(out, "city: Milan Old Street") {
+		t.Errorf("unexpected upgraded file:\n%s", out)
+	}
+}
@@ -0,0 +1,80 @@
+package versioningyaml
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ConversionError is returned when a value can't be converted to the type of the field it is migrated to,
+// because the field changed to an incompatible type or a custom migration returned a value of the wrong type
+type ConversionError struct {
+	// Source is the type of the value
+	Source reflect.Type
+	// Destination is the type of the field
+	Destination reflect.Type
+	// Path is the dotted path of the field
+	Path string
+	// Reason explains the error when the types alone don't, as the length of a value too long for an array
+	Reason string
+	// FromVersion and ToVersion are the versions of the migration step, zero if the
+	// error happened outside of a registered migration (MigrateOne).
+	// They are not part of the message since the step already wraps the error
+	FromVersion, ToVersion int
+}
+
+func (e *ConversionError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("field %s: %v is not convertible to %v: %s", e.Path, e.Source, e.Destination, e.Reason)
+	}
+	return fmt.Sprintf("field %s: %v is not convertible to %v", e.Path, e.Source, e.Destination)
+}
+
+// LossyMigrationError is returned in strict down mode (see Registry.SetStrictDown) by a DOWN step
+// that would lose the values of Fields
+type LossyMigrationError struct {
+	FromVersion, ToVersion int
+	// Fields are the dotted paths of the fields whose values would be lost
+	Fields []string
+}
+
+func (e *LossyMigrationError) Error() string {
+	return fmt.Sprintf("from version %d to %d: the values of %s would be lost, set a CustomMigration DOWN",
+		e.FromVersion, e.ToVersion, strings.Join(e.Fields, ", "))
+}
+
+// convert converts [value] to the type [t] of the field [path],
+// an invalid value (a nil returned by a custom migration) is the zero value of t
+func convert(value reflect.Value, t reflect.Type, path string) (reflect.Value, error) {
+	if !value.IsValid() {
+		return reflect.Zero(t), nil
+	}
+	if !convertible(value.Type(), t) {
+		return reflect.Value{}, &ConversionError{Source: value.Type(), Destination: t, Path: path}
+	}
+	return value.Convert(t), nil
+}
+
+// convertible tells if a value of type [from] can be converted to [to]. Integers are not convertible
+// to strings, since reflect would interpret them as runes ("1" would become "\x01"), and slices
+// are not convertible to arrays or pointers to arrays, since reflect panics if their lengths differ
+// (migrateValue migrates them element by element)
+func convertible(from, to reflect.Type) bool {
+	if to.Kind() == reflect.String && isInteger(from) {
+		return false
+	}
+	if from.Kind() == reflect.Slice && (to.Kind() == reflect.Array || to.Kind() == reflect.Ptr && to.Elem().Kind() == reflect.Array) {
+		return false
+	}
+	return from.ConvertibleTo(to)
+}
+
+// isInteger tells if [t] is a signed or unsigned integer type
+func isInteger(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
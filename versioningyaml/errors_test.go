@@ -0,0 +1,127 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+	versions "github.com/davide-camponogara/versioningyaml/versions_test"
+)
+
+var errNoName = errors.New("street without name")
+
+func TestCustomMigrationError(t *testing.T) {
+	cv := append([]utils.ConfigVersion(nil), versions.ConfigVersions...)
+	cv[2].UpE = utils.CustomMigrationE{
+		"Street.Name": func(c any) (any, error) {
+			conf := c.(versions.ConfigV2)
+			if conf.Street.Name == "" {
+				return nil, errNoName
+			}
+			return conf.Street.Name, nil
+		},
+	}
+	cv[2].Up = utils.CustomMigration{"TestV3": versions.UpV3["TestV3"]}
+	r := NewRegistry(nil)
+	if err := r.SetConfigVersionsStrict(cv); err != nil {
+		t.Fatal(err)
+	}
+
+	v3, err := r.MigrateUp(versions.ConfigV1{Version: 1, Street: versions.Street{Name: "Main"}}, versions.ConfigV3{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := v3.(*versions.ConfigV3).Street.Name; name != "Main" {
+		t.Fatalf("unexpected street name %q", name)
+	}
+
+	_, err = r.MigrateUp(versions.ConfigV1{Version: 1}, versions.ConfigV3{})
+	if !errors.Is(err, errNoName) {
+		t.Fatalf("expected the migration error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "from version 2 to 3") || !strings.Contains(err.Error(), "field Street.Name") {
+		t.Fatalf("expected the error wrapped with the step and the field, got %v", err)
+	}
+
+	// a key can't be set in both Up and UpE
+	cv[2].Up = versions.UpV3
+	if err := validateVersions(cv); err == nil || !strings.Contains(err.Error(), `"Street.Name" is set in both Up and UpE`) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+}
+
+type convV1 struct {
+	Version int
+	Test    map[int]bool
+}
+
+func (convV1) V() int { return 1 }
+
+type convV2 struct {
+	Version int
+	Test    map[string]bool
+	Port    int
+}
+
+func (convV2) V() int { return 2 }
+
+func TestConversionError(t *testing.T) {
+	r := NewRegistry([]utils.ConfigVersion{{Config: convV1{}}, {Config: convV2{}}})
+	_, err := r.MigrateUp(convV1{Version: 1, Test: map[int]bool{1: true}}, convV2{})
+	var convErr *ConversionError
+	if !errors.As(err, &convErr) {
+		t.Fatalf("expected a *ConversionError, got %v", err)
+	}
+	want := ConversionError{
+		Source:      reflect.TypeOf(map[int]bool{}),
+		Destination: reflect.TypeOf(map[string]bool{}),
+		Path:        "Test",
+		FromVersion: 1,
+		ToVersion:   2,
+	}
+	if *convErr != want {
+		t.Fatalf("got %#v, want %#v", *convErr, want)
+	}
+
+	// a custom migration returning the wrong type
+	err = MigrateOne(convV1{}, &convV2{}, utils.CustomMigration{
+		"Test": func(c any) any { return map[string]bool{} },
+		"Port": func(c any) any { return "8080" },
+	})
+	if !errors.As(err, &convErr) || convErr.Path != "Port" || convErr.FromVersion != 0 {
+		t.Fatalf("expected a *ConversionError on Port, got %v", err)
+	}
+
+	// nil is the zero value
+	dest := convV2{Port: 1}
+	if err := MigrateOne(convV1{}, &dest, utils.CustomMigration{
+		"Test": func(c any) any { return nil },
+		"Port": func(c any) any { return nil },
+	}); err != nil || dest.Port != 0 || dest.Test != nil {
+		t.Fatalf("unexpected result %#v, %v", dest, err)
+	}
+}
+
+type convArray struct {
+	Version int
+	Values  [3]int
+	Pointer *[3]int
+}
+
+func (convArray) V() int { return 2 }
+
+func TestConversionErrorSliceToArray(t *testing.T) {
+	for _, key := range []string{"Values", "Pointer"} {
+		err := MigrateOne(convV1{}, &convArray{}, utils.CustomMigration{
+			key: func(c any) any { return []int{1} },
+		})
+		var convErr *ConversionError
+		if !errors.As(err, &convErr) || convErr.Path != key {
+			t.Fatalf("expected a *ConversionError on %s, got %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package versioningyaml
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+)
+
+// LoadConfigVersionedFS loads the config file [path] of [fsys] as a struct of the related version
+// and returns also the version using the default registry.
+// It allows loading configs from embed.FS, fstest.MapFS or any other fs.FS
+func LoadConfigVersionedFS(fsys fs.FS, path string) (utils.Config, int, error) {
+	return defaultRegistry.LoadConfigVersionedFS(fsys, path)
+}
+
+// LoadConfigVersionedFS loads the config file [path] of [fsys] as a struct of the related version
+// and returns also the version.
+// It allows loading configs from embed.FS, fstest.MapFS or any other fs.FS
+func (r *Registry) LoadConfigVersionedFS(fsys fs.FS, path string) (utils.Config, int, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("loading config versioned: %w", err)
+	}
+	s := r.snapshot()
+	content, err := s.readFileFS(fsys, path)
+	if err != nil {
+		return nil, 0, wrapErr(err)
+	}
+	config, version, err := s.decodeBytes(content)
+	if err != nil {
+		return nil, 0, wrapErr(err)
+	}
+	return config, version, nil
+}
+
+// LoadLatestFS loads the config file [path] of [fsys] of any registered version and migrates it up
+// to the newest version using the default registry, see Registry.LoadLatest
+func LoadLatestFS(fsys fs.FS, path string) (utils.Config, int, error) {
+	return defaultRegistry.LoadLatestFS(fsys, path)
+}
+
+// LoadLatestFS loads the config file [path] of [fsys] of any registered version and migrates it up
+// to the newest version, see Registry.LoadLatest
+func (r *Registry) LoadLatestFS(fsys fs.FS, path string) (utils.Config, int, error) {
+	s := r.snapshot()
+	content, err := s.readFileFS(fsys, path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("loading latest config: %w", err)
+	}
+	return s.loadLatest(content)
+}
+
+// LoadFS loads the config file [path] of [fsys] of any registered version up to the one of T
+// and migrates it up to T using the default registry, see Load
+func LoadFS[T utils.Config](fsys fs.FS, path string) (T, error) {
+	return LoadFSWith[T](defaultRegistry, fsys, path)
+}
+
+// LoadFSWith loads the config file [path] of [fsys] of any registered version up to the one of T
+// and migrates it up to T using the registry [r], see Load
+func LoadFSWith[T utils.Config](r *Registry, fsys fs.FS, path string) (T, error) {
+	s := r.snapshot()
+	content, err := s.readFileFS(fsys, path)
+	if err != nil {
+		var zero T
+		return zero, fmt.Errorf("loading config as %v: %w", typeOf[T](), err)
+	}
+	return loadUpTo[T](s, content)
+}
+
+// readFileFS reads the file [path] of [fsys]
+func (s *snapshot) readFileFS(fsys fs.FS, path string) ([]byte, error) {
+	content, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	s.logger.Debug("config read", "path", path, "bytes", len(content))
+	return content, nil
+}
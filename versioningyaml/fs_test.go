@@ -0,0 +1,49 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+
+	versions "github.com/davide-camponogara/versioningyaml/versions_test"
+)
+
+func TestLoadFS(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+
+	content, err := os.ReadFile("testdata/configv1.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"configs/app.yaml": {Data: content}}
+
+	config, version, err := r.LoadConfigVersionedFS(fsys, "configs/app.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 || config.(versions.ConfigV1).ZipCode != 20100 {
+		t.Fatalf("unexpected config version %d: %#v", version, config)
+	}
+
+	latest, _, err := r.LoadLatestFS(fsys, "configs/app.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.V() != 3 {
+		t.Fatalf("expected version 3, got %d", latest.V())
+	}
+
+	v2, err := LoadFSWith[*versions.ConfigV2](r, fsys, "configs/app.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.City != "Milan Old Street" {
+		t.Fatalf("unexpected config %#v", v2)
+	}
+
+	if _, _, err := r.LoadConfigVersionedFS(fsys, "configs/missing.yaml"); err == nil {
+		t.Fatal("expected error loading a missing file")
+	}
+}
@@ -0,0 +1,167 @@
+package versioningyaml
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+)
+
+// MigrateUpTo applies the UP migrations from [source] to the version of T using the default registry.
+// T can be either the Config struct or a pointer to it
+func MigrateUpTo[T utils.Config](source utils.Config) (T, error) {
+	return MigrateUpToWith[T](defaultRegistry, source)
+}
+
+// MigrateUpToWith applies the UP migrations from [source] to the version of T using the registry [r].
+// T can be either the Config struct or a pointer to it
+func MigrateUpToWith[T utils.Config](r *Registry, source utils.Config) (T, error) {
+	var zero T
+	wrapErr := func(err error) error {
+		return fmt.Errorf("migrating up to %v: %w", typeOf[T](), err)
+	}
+	if isNil(source) {
+		return zero, wrapErr(errors.New("source is nil"))
+	}
+	s := r.snapshot()
+
+	target, err := configOf[T]()
+	if err != nil {
+		return zero, wrapErr(err)
+	}
+	_, from := s.findByVersion(source.V())
+	_, to := s.findByVersion(target.V())
+	if from > to && to >= 0 {
+		return zero, wrapErr(fmt.Errorf("source version %d is newer than version %d", source.V(), target.V()))
+	}
+
+	c, err := s.migrateUp(context.Background(), source, target)
+	if err != nil {
+		return zero, wrapErr(err)
+	}
+	t, err := asType[T](c)
+	if err != nil {
+		return zero, wrapErr(err)
+	}
+	return t, nil
+}
+
+// LoadAs loads a config file of any registered version and migrates it UP or DOWN to the version of T
+// using the default registry. T can be either the Config struct or a pointer to it
+func LoadAs[T utils.Config](path string) (T, error) {
+	return LoadAsWith[T](defaultRegistry, path)
+}
+
+// LoadAsWith loads a config file of any registered version and migrates it UP or DOWN to the version of T
+// using the registry [r]. T can be either the Config struct or a pointer to it
+func LoadAsWith[T utils.Config](r *Registry, path string) (T, error) {
+	var zero T
+	wrapErr := func(err error) error {
+		return fmt.Errorf("loading config as %v: %w", typeOf[T](), err)
+	}
+	s := r.snapshot()
+
+	target, err := configOf[T]()
+	if err != nil {
+		return zero, wrapErr(err)
+	}
+	_, to := s.findByVersion(target.V())
+	if to < 0 {
+		return zero, wrapErr(fmt.Errorf("version %d not registered", target.V()))
+	}
+	content, err := s.readFile(path)
+	if err != nil {
+		return zero, wrapErr(err)
+	}
+	c, _, err := s.decodeTo(content, to, true)
+	if err != nil {
+		return zero, wrapErr(err)
+	}
+	t, err := asType[T](c)
+	if err != nil {
+		return zero, wrapErr(err)
+	}
+	return t, nil
+}
+
+// Write creates a yaml file in [path] from the tagged [data] struct using the default registry.
+// T can be either the Config struct or a pointer to it
+func Write[T utils.Config](data T, path string) error {
+	return WriteWith(defaultRegistry, data, path)
+}
+
+// WriteWith creates a yaml file in [path] from the tagged [data] struct using the registry [r].
+// T can be either the Config struct or a pointer to it
+func WriteWith[T utils.Config](r *Registry, data T, path string) error {
+	value := reflect.ValueOf(data)
+	if value.Kind() == reflect.Ptr && value.IsNil() {
+		return fmt.Errorf("writing yaml: nil %v", typeOf[T]())
+	}
+	return r.WriteYaml(data, path)
+}
+
+// typeOf returns the type T, also when T is an interface
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// configOf returns a Config of type T usable to call V(), when T is a pointer
+// it points to a zero struct instead of being nil. An interface T (utils.Config itself)
+// has no zero value to call V() on and is an error
+func configOf[T utils.Config]() (utils.Config, error) {
+	t := typeOf[T]()
+	switch t.Kind() {
+	case reflect.Interface:
+		return nil, fmt.Errorf("T must be a Config struct or pointer to it, not the interface %v", t)
+	case reflect.Ptr:
+		return reflect.New(t.Elem()).Interface().(utils.Config), nil
+	}
+	return reflect.Zero(t).Interface().(utils.Config), nil
+}
+
+// asType returns [c] as T, dereferencing it or taking its address when only one of them is a pointer
+func asType[T utils.Config](c utils.Config) (T, error) {
+	if t, ok := c.(T); ok {
+		return t, nil
+	}
+	var zero T
+	target := typeOf[T]()
+	value := reflect.ValueOf(c)
+	switch {
+	case value.Kind() == reflect.Ptr && value.Type().Elem() == target:
+		return value.Elem().Interface().(T), nil
+	case target.Kind() == reflect.Ptr && target.Elem() == value.Type():
+		return toPointer(c).(T), nil
+	}
+	return zero, fmt.Errorf("config of type %T is not %v", c, target)
+}
+
+// toPointer returns a pointer to a copy of [c] if [c] is not already a pointer
+func toPointer(c utils.Config) utils.Config {
+	value := reflect.ValueOf(c)
+	if value.Kind() == reflect.Ptr {
+		return c
+	}
+	p := reflect.New(value.Type())
+	p.Elem().Set(value)
+	return p.Interface().(utils.Config)
+}
+
+// copyConfig returns a pointer to a copy of [c], also when [c] is already a pointer
+func copyConfig(c utils.Config) utils.Config {
+	value := reflect.Indirect(reflect.ValueOf(c))
+	p := reflect.New(value.Type())
+	p.Elem().Set(value)
+	return p.Interface().(utils.Config)
+}
+
+// isNil tells if [c] is nil or a nil pointer, whose V() would panic
+func isNil(c utils.Config) bool {
+	if c == nil {
+		return true
+	}
+	value := reflect.ValueOf(c)
+	return value.Kind() == reflect.Ptr && value.IsNil()
+}
@@ -0,0 +1,58 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+	versions "github.com/davide-camponogara/versioningyaml/versions_test"
+)
+
+func TestGenericAPI(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+	r.SetLongComments(versions.LongComments)
+
+	config, _, err := r.LoadConfigVersioned("testdata/configv1.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v3, err := MigrateUpToWith[*versions.ConfigV3](r, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v3.Version != 3 || v3.City != "Milan Old Street" {
+		t.Fatalf("unexpected migrated config %#v", v3)
+	}
+	if _, err := MigrateUpToWith[versions.ConfigV1](r, v3); err == nil {
+		t.Fatal("expected error migrating up to an older version")
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := WriteWith(r, v3, path); err != nil {
+		t.Fatal(err)
+	}
+
+	v1, err := LoadAsWith[versions.ConfigV1](r, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v1.Version != 1 || v1.City != v3.Street.Name {
+		t.Fatalf("unexpected config migrated down %#v", v1)
+	}
+}
+
+func TestGenericAPIInterface(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+
+	if _, err := LoadWith[utils.Config](r, "testdata/configv1.yaml"); err == nil {
+		t.Fatal("expected error loading as the Config interface")
+	}
+	if _, err := LoadAsWith[utils.Config](r, "testdata/configv1.yaml"); err == nil {
+		t.Fatal("expected error loading as the Config interface")
+	}
+	if _, err := MigrateUpToWith[utils.Config](r, versions.ConfigV1{Version: 1}); err == nil {
+		t.Fatal("expected error migrating up to the Config interface")
+	}
+}
@@ -0,0 +1,136 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+)
+
+type hookV1 struct {
+	Version int
+	Address string
+}
+
+func (hookV1) V() int { return 1 }
+
+type hookStreet struct {
+	Name   string
+	Number int
+}
+
+type hookV2 struct {
+	Version int
+	Street  hookStreet
+	Source  string
+}
+
+func (hookV2) V() int { return 2 }
+
+var hookVersions = []utils.ConfigVersion{
+	{Config: hookV1{}},
+	{
+		Config: hookV2{},
+		BeforeUp: func(ctx context.Context, source, dest any) error {
+			dest.(*hookV2).Source = "migrated"
+			return nil
+		},
+		// splits the address in name and number
+		AfterUp: func(ctx context.Context, source, dest any) error {
+			address := source.(hookV1).Address
+			i := strings.LastIndex(address, " ")
+			if i < 0 {
+				return fmt.Errorf("address %q without number", address)
+			}
+			d := dest.(*hookV2)
+			d.Street.Name = address[:i]
+			_, err := fmt.Sscan(address[i+1:], &d.Street.Number)
+			return err
+		},
+		AfterDown: func(ctx context.Context, source, dest any) error {
+			s := source.(hookV2)
+			dest.(*hookV1).Address = fmt.Sprintf("%s %d", s.Street.Name, s.Street.Number)
+			return nil
+		},
+	},
+}
+
+func TestHooks(t *testing.T) {
+	r := NewRegistry(nil)
+	if err := r.SetConfigVersionsStrict(hookVersions); err != nil {
+		t.Fatal(err)
+	}
+	r.SetStrictDown(true)
+
+	up, err := r.MigrateUp(hookV1{Version: 1, Address: "Via Roma 12"}, hookV2{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := hookV2{Version: 2, Street: hookStreet{"Via Roma", 12}, Source: "migrated"}
+	if got := *up.(*hookV2); got != want {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+
+	// AfterDown handles the fields missing in the old version
+	down, err := r.MigrateDown(up, hookV1{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := *down.(*hookV1); got != (hookV1{Version: 1, Address: "Via Roma 12"}) {
+		t.Fatalf("unexpected config %#v", got)
+	}
+
+	_, err = r.MigrateUp(hookV1{Version: 1, Address: "Roma"}, hookV2{})
+	if err == nil || !strings.Contains(err.Error(), "from version 1 to 2: after hook: address \"Roma\" without number") {
+		t.Fatalf("expected hook error, got %v", err)
+	}
+}
+
+func TestValidateHooks(t *testing.T) {
+	hook := func(ctx context.Context, source, dest any) error { return errors.New("never called") }
+	cv := []utils.ConfigVersion{
+		{Version: 1, BeforeDown: hook},
+		{Config: hookV2{}, NodeUp: func(map[string]any) error { return nil }, NodeDown: func(map[string]any) error { return nil }, AfterUp: hook},
+	}
+	err := validateVersions(cv)
+	verr, ok := err.(*ValidationError)
+	if !ok || len(verr.Problems) != 3 {
+		t.Fatalf("expected 3 problems, got %v", err)
+	}
+}
+
+type hookV3 struct {
+	Version int
+	Street  hookStreet
+	Label   string
+}
+
+func (hookV3) V() int { return 3 }
+
+func TestHooksMultiStep(t *testing.T) {
+	cv := append(append([]utils.ConfigVersion(nil), hookVersions...), utils.ConfigVersion{
+		Config: hookV3{},
+		// the source is a struct both migrating from version 2 and in the second step from version 1
+		AfterUp: func(ctx context.Context, source, dest any) error {
+			s := source.(hookV2)
+			dest.(*hookV3).Label = fmt.Sprintf("%s %d", s.Street.Name, s.Street.Number)
+			return nil
+		},
+	})
+	r := NewRegistry(cv)
+
+	for _, source := range []utils.Config{hookV1{Version: 1, Address: "Via Roma 12"}, hookV2{Version: 2, Street: hookStreet{"Via Roma", 12}}} {
+		up, err := r.MigrateUp(source, hookV3{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if label := up.(*hookV3).Label; label != "Via Roma 12" {
+			t.Fatalf("migrating up from version %d: unexpected label %q", source.V(), label)
+		}
+	}
+}
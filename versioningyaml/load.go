@@ -0,0 +1,117 @@
+package versioningyaml
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+)
+
+// LoadLatest loads a config file of any registered version and migrates it up to the newest version
+// using the default registry, see Registry.LoadLatest
+func LoadLatest(path string) (utils.Config, int, error) {
+	return defaultRegistry.LoadLatest(path)
+}
+
+// LoadLatest loads a config file of any registered version and migrates it up to the newest version.
+// It returns a pointer to the struct of the newest version and the version of the file
+func (r *Registry) LoadLatest(path string) (utils.Config, int, error) {
+	s := r.snapshot()
+	content, err := s.readFile(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("loading latest config: %w", err)
+	}
+	return s.loadLatest(content)
+}
+
+// loadLatest decodes the yaml [content] and migrates it up to the newest version
+func (s *snapshot) loadLatest(content []byte) (utils.Config, int, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("loading latest config: %w", err)
+	}
+	if len(s.configVersions) == 0 {
+		return nil, 0, wrapErr(errors.New("no config versions registered"))
+	}
+
+	latest, version, err := s.decodeUpTo(content, len(s.configVersions)-1)
+	if err != nil {
+		return nil, 0, wrapErr(err)
+	}
+	return toPointer(latest), version, nil
+}
+
+// decodeUpTo decodes the yaml [content] of any version up to the one at index [to] and migrates it up to it,
+// it returns the migrated config and the version of [content]
+func (s *snapshot) decodeUpTo(content []byte, to int) (utils.Config, int, error) {
+	return s.decodeTo(content, to, false)
+}
+
+// decodeTo decodes the yaml [content] of any version and migrates it to the one at index [to],
+// a newer [content] is migrated down only if [down] is set. Retired versions are decoded as yaml trees
+// and migrated by their node migrations
+func (s *snapshot) decodeTo(content []byte, to int, down bool) (utils.Config, int, error) {
+	decoded, version, err := s.decode(content)
+	if err != nil {
+		return nil, 0, err
+	}
+	_, from := s.findByVersion(version)
+	if from > to && !down {
+		return nil, 0, fmt.Errorf("config version %d is newer than version %d", version, versionNumber(s.configVersions[to]))
+	}
+
+	migrated, err := s.migrate(context.Background(), decoded, from, to)
+	if err != nil {
+		return nil, 0, err
+	}
+	config, ok := migrated.(utils.Config)
+	if !ok {
+		return nil, 0, fmt.Errorf("version %d is retired and has no Config struct", versionNumber(s.configVersions[to]))
+	}
+	return config, version, nil
+}
+
+// Load loads a config file of any registered version up to the one of T and migrates it up to T
+// using the default registry. T can be either the Config struct or a pointer to it
+func Load[T utils.Config](path string) (T, error) {
+	return LoadWith[T](defaultRegistry, path)
+}
+
+// LoadWith loads a config file of any registered version up to the one of T and migrates it up to T
+// using the registry [r]. T can be either the Config struct or a pointer to it
+func LoadWith[T utils.Config](r *Registry, path string) (T, error) {
+	s := r.snapshot()
+	content, err := s.readFile(path)
+	if err != nil {
+		var zero T
+		return zero, fmt.Errorf("loading config as %v: %w", typeOf[T](), err)
+	}
+	return loadUpTo[T](s, content)
+}
+
+// loadUpTo decodes the yaml [content] and migrates it up to T
+func loadUpTo[T utils.Config](s *snapshot, content []byte) (T, error) {
+	var zero T
+	wrapErr := func(err error) error {
+		return fmt.Errorf("loading config as %v: %w", typeOf[T](), err)
+	}
+
+	target, err := configOf[T]()
+	if err != nil {
+		return zero, wrapErr(err)
+	}
+	_, to := s.findByVersion(target.V())
+	if to < 0 {
+		return zero, wrapErr(fmt.Errorf("version %d not registered", target.V()))
+	}
+
+	c, _, err := s.decodeUpTo(content, to)
+	if err != nil {
+		return zero, wrapErr(err)
+	}
+	t, err := asType[T](c)
+	if err != nil {
+		return zero, wrapErr(err)
+	}
+	return t, nil
+}
@@ -0,0 +1,53 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"testing"
+
+	versions "github.com/davide-camponogara/versioningyaml/versions_test"
+)
+
+func TestLoadLatest(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+
+	config, version, err := r.LoadLatest("testdata/configv1.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 {
+		t.Fatalf("expected original version 1, got %d", version)
+	}
+	c, ok := config.(*versions.ConfigV3)
+	if !ok {
+		t.Fatalf("expected *ConfigV3, got %T", config)
+	}
+	if c.City != "Milan Old Street" || c.Street.Name != "7 Old Street" || c.Version != 3 {
+		t.Fatalf("unexpected migrated config %#v", c)
+	}
+}
+
+func TestLoadTyped(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+
+	v2, err := LoadWith[versions.ConfigV2](r, "testdata/configv1.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.City != "Milan Old Street" || v2.Version != 2 {
+		t.Fatalf("unexpected migrated config %#v", v2)
+	}
+
+	// a file already at the requested version is returned as is
+	p2, err := LoadWith[*versions.ConfigV2](r, "testdata/configv2.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p2.City != "Rome" {
+		t.Fatalf("unexpected config %#v", p2)
+	}
+
+	if _, err := LoadWith[versions.ConfigV1](r, "testdata/configv2.yaml"); err == nil {
+		t.Fatal("expected error loading a newer file as an older version")
+	}
+}
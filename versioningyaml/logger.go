@@ -0,0 +1,30 @@
+package versioningyaml
+
+// Logger receives the structured events of a Registry: reading of a config, detection of its version,
+// every migration step and writing. Arguments are alternated keys and values as in log/slog,
+// so a *slog.Logger can be used directly.
+//
+// By default a Registry doesn't log anything.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+}
+
+// nopLogger is the Logger that discards every event
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, args ...any) {}
+func (nopLogger) Info(msg string, args ...any)  {}
+
+// SetLogger sets the logger of the registry, nil disables logging
+func (r *Registry) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = nopLogger{}
+	}
+	r.update(func(s *snapshot) { s.logger = logger })
+}
+
+// SetLogger sets the logger of the default registry, nil disables logging
+func SetLogger(logger Logger) {
+	defaultRegistry.SetLogger(logger)
+}
@@ -0,0 +1,67 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	versions "github.com/davide-camponogara/versioningyaml/versions_test"
+)
+
+// recordLogger records the events received as "msg key=value ..."
+type recordLogger struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *recordLogger) record(msg string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := 0; i+1 < len(args); i += 2 {
+		if args[i] != "path" && args[i] != "bytes" {
+			msg += fmt.Sprintf(" %v=%v", args[i], args[i+1])
+		}
+	}
+	l.events = append(l.events, msg)
+}
+
+func (l *recordLogger) Debug(msg string, args ...any) { l.record(msg, args...) }
+func (l *recordLogger) Info(msg string, args ...any)  { l.record(msg, args...) }
+
+func TestLoggerEvents(t *testing.T) {
+	logger := &recordLogger{}
+	r := NewRegistry(versions.ConfigVersions)
+	r.SetLogger(logger)
+
+	config, _, err := r.LoadLatest("testdata/configv1.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WriteYaml(config, filepath.Join(t.TempDir(), "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"config read",
+		"version detected version=1",
+		"migration step from=1 to=2",
+		"migration step from=2 to=3",
+		"config written version=3",
+	}
+	if !reflect.DeepEqual(logger.events, expected) {
+		t.Fatalf("expected events %q, got %q", expected, logger.events)
+	}
+
+	// nil disables logging
+	r.SetLogger(nil)
+	if _, _, err := r.LoadLatest("testdata/configv1.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if len(logger.events) != len(expected) {
+		t.Fatalf("unexpected events after disabling the logger %q", logger.events[len(expected):])
+	}
+}
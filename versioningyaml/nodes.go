@@ -0,0 +1,43 @@
+package versioningyaml
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// toTree converts the Config struct (or pointer to it) [config] to its yaml tree
+func toTree(config interface{}) (map[string]interface{}, error) {
+	content, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("error converting to yaml tree: %w", err)
+	}
+	var tree map[string]interface{}
+	if err := unmarshalYAML(content, &tree); err != nil {
+		return nil, err
+	}
+	if tree == nil {
+		tree = map[string]interface{}{}
+	}
+	return tree, nil
+}
+
+// fromTree decodes the yaml [tree] as a new struct of the type of [config] returning a pointer to it
+func fromTree(tree map[string]interface{}, config utils.Config) (utils.Config, error) {
+	content, err := yaml.Marshal(tree)
+	if err != nil {
+		return nil, fmt.Errorf("error converting yaml tree: %w", err)
+	}
+	value := reflect.New(reflect.TypeOf(config))
+	if err := unmarshalYAML(content, value.Interface()); err != nil {
+		return nil, err
+	}
+	// the version field is set also when its yaml key is not "version"
+	if field := value.Elem().FieldByName("Version"); field.IsValid() && field.CanSet() && field.Kind() >= reflect.Int && field.Kind() <= reflect.Int64 {
+		field.SetInt(int64(config.V()))
+	}
+	return value.Interface().(utils.Config), nil
+}
@@ -0,0 +1,113 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+	versions "github.com/davide-camponogara/versioningyaml/versions_test"
+)
+
+// retiredVersions are the example versions with ConfigV1 retired
+var retiredVersions = []utils.ConfigVersion{
+	{
+		Version: 1,
+	},
+	{
+		Config: versions.ConfigV2{},
+		NodeUp: func(tree map[string]any) error {
+			name, _ := utils.TreeGet(tree, "street.Name")
+			if err := utils.TreeSet(tree, "city", fmt.Sprintf("%v %v", tree["city"], name)); err != nil {
+				return err
+			}
+			return utils.TreeDelete(tree, "zipcode")
+		},
+		NodeDown: func(tree map[string]any) error {
+			if err := utils.TreeDelete(tree, "test"); err != nil {
+				return err
+			}
+			return utils.TreeSetDefault(tree, "zipcode", 0)
+		},
+	},
+	{
+		Config: versions.ConfigV3{},
+		Up:     versions.UpV3,
+		Down:   versions.DownV3,
+	},
+}
+
+func TestRetiredVersion(t *testing.T) {
+	r := NewRegistry(nil)
+	if err := r.SetConfigVersionsStrict(retiredVersions); err != nil {
+		t.Fatal(err)
+	}
+
+	config, version, err := r.LoadLatest("testdata/configv1.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v3 := config.(*versions.ConfigV3)
+	if version != 1 || v3.City != "Milan Old Street" || v3.Street.Name != "7 Old Street" || v3.Version != 3 {
+		t.Fatalf("unexpected config version %d: %#v", version, v3)
+	}
+
+	// LoadAs accepts the same files as Load
+	v2, err := LoadAsWith[*versions.ConfigV2](r, "testdata/configv1.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.Version != 2 || v2.City != "Milan Old Street" {
+		t.Fatalf("unexpected config loaded as v2: %#v", v2)
+	}
+	if _, err := LoadAsWith[versions.ConfigV1](r, "testdata/configv1.yaml"); err == nil {
+		t.Fatal("expected error loading as a retired version")
+	}
+
+	if _, _, err := r.LoadConfigVersioned("testdata/configv1.yaml"); err == nil || !strings.Contains(err.Error(), "retired") {
+		t.Fatalf("expected error loading a retired version as struct, got %v", err)
+	}
+	if _, err := r.MigrateTo(v3, 1); err == nil {
+		t.Fatal("expected error migrating to a retired version")
+	}
+
+	// migrating down to the retired version produces its yaml tree
+	s := r.snapshot()
+	tree, err := s.migrate(context.Background(), v3, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.(map[string]any)["zipcode"] != 0 || tree.(map[string]any)["version"] != 1 {
+		t.Fatalf("unexpected tree %#v", tree)
+	}
+
+	result, err := r.UpgradeFile("testdata/configv1.yaml", UpgradeOptions{DryRun: true, PreserveComments: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := string(result.Content); strings.Contains(out, "zipcode") || !strings.Contains(out, "# Test commento numeor 1\nstreet:") {
+		t.Fatalf("unexpected upgraded file:\n%s", out)
+	}
+}
+
+func TestValidateRetiredVersion(t *testing.T) {
+	cv := []utils.ConfigVersion{
+		{Version: 1},
+		{Config: versions.ConfigV2{}, Up: versions.UpV2},
+	}
+	err := validateVersions(cv)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, expected := range []string{
+		"index 1: Up custom migrations need the Config struct of the version before",
+		"index 1: NodeDown is needed to migrate down to the retired version at index 0",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in %v", expected, err)
+		}
+	}
+}
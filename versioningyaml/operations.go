@@ -0,0 +1,305 @@
+package versioningyaml
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+)
+
+// applyOperations applies the declarative operations [ops] from the [sourceValue] struct
+// to the [destValue] one, skipping the ones on paths with a custom migration
+func applyOperations(sourceValue, destValue reflect.Value, ops []utils.Operation, migration utils.CustomMigrationContext) error {
+	for _, op := range ops {
+		if _, ok := migration[op.Path()]; ok {
+			continue
+		}
+		if err := applyOperation(sourceValue, destValue, op); err != nil {
+			return fmt.Errorf("operation %v: %w", op, err)
+		}
+	}
+	return nil
+}
+
+// downOperations returns the operations that undo the UP operations [ops] migrating down to [destType].
+// SetDefault is undone dropping the field only when it is new, a field that exists also
+// in destType keeps its value (nil destType, a retired version, is assumed not to have it)
+func downOperations(ops []utils.Operation, destType reflect.Type) []utils.Operation {
+	var undone []utils.Operation
+	for _, op := range ops {
+		if op.Kind == utils.SetDefaultOp && destType != nil {
+			if _, err := fieldByPath(destType, op.To); err == nil {
+				continue
+			}
+		}
+		undone = append(undone, op)
+	}
+	return utils.InverseOperations(undone)
+}
+
+func applyOperation(sourceValue, destValue reflect.Value, op utils.Operation) error {
+	switch op.Kind {
+	case utils.RenameOp, utils.MoveOp:
+		sourceField, err := valueByPath(sourceValue, op.From)
+		if err != nil {
+			return err
+		}
+		destField, ok, err := destByPath(destValue, op.To, sourceField)
+		if !ok {
+			return err
+		}
+		return assign(destField, sourceField, op.To)
+	case utils.DropOp:
+		// the dropped field is usually not in the new version, otherwise it is reset
+		// a field under a nil pointer has already the zero value
+		if destField, err := valueByPath(destValue, op.From); err == nil && destField.CanSet() {
+			destField.Set(reflect.Zero(destField.Type()))
+		}
+	case utils.SetDefaultOp:
+		destField, err := settableByPath(destValue, op.To)
+		if err != nil {
+			return err
+		}
+		if destField.IsZero() {
+			return assign(destField, reflect.ValueOf(op.Value), op.To)
+		}
+	case utils.ScaleOp:
+		sourceField, err := valueByPath(sourceValue, op.From)
+		if err != nil {
+			return err
+		}
+		destField, ok, err := destByPath(destValue, op.To, sourceField)
+		if !ok {
+			return err
+		}
+		return scale(destField, sourceField, op.Value, op.To)
+	case utils.WrapOp:
+		sourceField, err := valueByPath(sourceValue, op.From)
+		if err != nil {
+			return err
+		}
+		destField, ok, err := destByPath(destValue, op.To, sourceField)
+		if !ok {
+			return err
+		}
+		if destField.Kind() != reflect.Slice {
+			return fmt.Errorf("%s is not a slice", op.To)
+		}
+		if sourceField.IsZero() {
+			destField.Set(reflect.Zero(destField.Type()))
+			return nil
+		}
+		wrapped := reflect.MakeSlice(destField.Type(), 1, 1)
+		if err := assign(wrapped.Index(0), sourceField, op.To+"[0]"); err != nil {
+			return err
+		}
+		destField.Set(wrapped)
+	case utils.UnwrapOp:
+		sourceField, err := valueByPath(sourceValue, op.From)
+		if err != nil {
+			return err
+		}
+		destField, ok, err := destByPath(destValue, op.To, sourceField)
+		if !ok {
+			return err
+		}
+		if sourceField.Kind() != reflect.Slice && sourceField.Kind() != reflect.Array {
+			return fmt.Errorf("%s is not a slice", op.From)
+		}
+		switch sourceField.Len() {
+		case 0:
+			destField.Set(reflect.Zero(destField.Type()))
+		case 1:
+			return assign(destField, sourceField.Index(0), op.To)
+		default:
+			return fmt.Errorf("%s has %d elements, only one can be unwrapped", op.From, sourceField.Len())
+		}
+	default:
+		return fmt.Errorf("unknown operation kind %d", op.Kind)
+	}
+	return nil
+}
+
+// scale sets in [field] with the dotted [path] the numeric [value] multiplied by [factor]
+func scale(field, value reflect.Value, factor any, path string) error {
+	f, ok := factor.(float64)
+	if !ok {
+		return fmt.Errorf("factor %v is not a float64", factor)
+	}
+	var scaled float64
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		scaled = float64(value.Int()) * f
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		scaled = float64(value.Uint()) * f
+	case reflect.Float32, reflect.Float64:
+		scaled = value.Float() * f
+	default:
+		return fmt.Errorf("%v is not a number", value.Type())
+	}
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		scaled = math.Round(scaled)
+	}
+	return assign(field, reflect.ValueOf(scaled), path)
+}
+
+// lostFields returns the paths of the fields of the struct [sourceValue] with a value that would be lost
+// migrating it down to [destType] with the inverses of the UP operations [ops] and without custom migrations:
+// the ones missing in destType, not moved back by an operation and without the value set by SetDefault,
+// the ones reset by a Drop and the integer ones a Scale can't restore exactly
+func lostFields(sourceValue reflect.Value, destType reflect.Type, ops []utils.Operation) []string {
+	defaults := map[string]any{}
+	for _, op := range ops {
+		if op.Kind == utils.SetDefaultOp {
+			defaults[op.To] = op.Value
+		}
+	}
+	moved := map[string]bool{}
+	var lost []string
+	for _, op := range downOperations(ops, destType) {
+		switch op.Kind {
+		case utils.DropOp:
+			// a field missing in destType is checked by walkLost, one that exists is reset
+			if _, err := fieldByPath(destType, op.From); err != nil {
+				continue
+			}
+			if value, err := valueByPath(sourceValue, op.From); err == nil && !value.IsZero() {
+				lost = append(lost, op.From)
+			}
+		case utils.ScaleOp:
+			moved[op.From] = true
+			if inexactScale(sourceValue, destType, op) {
+				lost = append(lost, op.From)
+			}
+		default:
+			moved[op.From] = true
+		}
+	}
+	return append(walkLost(sourceValue, destType, "", moved, defaults), lost...)
+}
+
+// inexactScale tells if the Scale [op] rounds the value of the struct [sourceValue] it sets
+// in an integer field of [destType]
+func inexactScale(sourceValue reflect.Value, destType reflect.Type, op utils.Operation) bool {
+	factor, ok := op.Value.(float64)
+	if !ok {
+		return false
+	}
+	value, err := valueByPath(sourceValue, op.From)
+	if err != nil {
+		return false
+	}
+	destField, err := fieldByPath(destType, op.To)
+	if err != nil || !isInteger(destField.Type) {
+		return false
+	}
+	var scaled float64
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		scaled = float64(value.Int()) * factor
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		scaled = float64(value.Uint()) * factor
+	case reflect.Float32, reflect.Float64:
+		scaled = value.Float() * factor
+	default:
+		return false
+	}
+	// tolerate the error of the inverse factor, 1/1000 is not exact
+	return math.Abs(scaled-math.Round(scaled)) > 1e-9*math.Max(1, math.Abs(scaled))
+}
+
+func walkLost(sourceValue reflect.Value, destType reflect.Type, prefix string, moved map[string]bool, defaults map[string]any) []string {
+	var lost []string
+	for i := 0; i < sourceValue.NumField(); i++ {
+		field := sourceValue.Type().Field(i)
+		path := prefix + field.Name
+		value := sourceValue.Field(i)
+		if moved[path] || field.Name == "Version" {
+			continue
+		}
+		destField, ok := destType.FieldByName(field.Name)
+		switch {
+		case !ok:
+			if !value.IsZero() && !isDefault(value, defaults, path) {
+				lost = append(lost, path)
+			}
+		case field.Type.Kind() == reflect.Struct && destField.Type.Kind() == reflect.Struct:
+			lost = append(lost, walkLost(value, destField.Type, path+".", moved, defaults)...)
+		}
+	}
+	return lost
+}
+
+// valueByPath returns the field of the struct [v] addressed by the dotted [path] following pointers,
+// as fieldByPath does. A field under a nil pointer is returned as its (not settable) zero value
+func valueByPath(v reflect.Value, path string) (reflect.Value, error) {
+	return lookupPath(v, path, false)
+}
+
+// settableByPath returns the field of the struct [v] addressed by the dotted [path] as valueByPath does,
+// allocating the nil pointers on the way so that the field can be set
+func settableByPath(v reflect.Value, path string) (reflect.Value, error) {
+	return lookupPath(v, path, true)
+}
+
+// destByPath returns the field of the struct [v] addressed by the dotted [path] where [source] is set.
+// The nil pointers on the way are allocated unless source is the zero value: the field under them
+// is already zero and ok is false, there is nothing to set
+func destByPath(v reflect.Value, path string, source reflect.Value) (field reflect.Value, ok bool, err error) {
+	if source.IsZero() {
+		field, err = valueByPath(v, path)
+		return field, err == nil && field.CanSet(), err
+	}
+	field, err = settableByPath(v, path)
+	return field, err == nil, err
+}
+
+func lookupPath(v reflect.Value, path string, alloc bool) (reflect.Value, error) {
+	for _, name := range strings.Split(path, ".") {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					v = reflect.Zero(v.Type().Elem())
+					continue
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("%s: parent of %s is not a struct", path, name)
+		}
+		v = v.FieldByName(name)
+		if !v.IsValid() {
+			return reflect.Value{}, fmt.Errorf("%s: field %s not found", path, name)
+		}
+	}
+	return v, nil
+}
+
+// assign sets [value] in [field] converting it to the type of the field with the dotted [path]
+func assign(field reflect.Value, value reflect.Value, path string) error {
+	if !field.CanSet() {
+		return fmt.Errorf("field %s of type %v can't be set", path, field.Type())
+	}
+	converted, err := convert(value, field.Type(), path)
+	if err != nil {
+		return err
+	}
+	field.Set(converted)
+	return nil
+}
+
+// isDefault tells if [value] is the one set by SetDefault on [path]
+func isDefault(value reflect.Value, defaults map[string]any, path string) bool {
+	def, ok := defaults[path]
+	if !ok || def == nil {
+		return false
+	}
+	d := reflect.ValueOf(def)
+	return d.Type().ConvertibleTo(value.Type()) && reflect.DeepEqual(d.Convert(value.Type()).Interface(), value.Interface())
+}
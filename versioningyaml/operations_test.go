@@ -0,0 +1,353 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+)
+
+type opsStreetV1 struct {
+	Name   string
+	Number int
+}
+
+type opsV1 struct {
+	Version int
+	Street  opsStreetV1
+	Owner   string
+	Legacy  bool
+}
+
+func (c opsV1) V() int { return 1 }
+
+type opsStreetV2 struct {
+	Label  string
+	Number int
+}
+
+type opsV2 struct {
+	Version int
+	Street  opsStreetV2
+	Holder  string
+	Port    int64
+}
+
+func (c opsV2) V() int { return 2 }
+
+var opsVersions = []utils.ConfigVersion{
+	{Config: opsV1{}},
+	{
+		Config: opsV2{},
+		Ops: []utils.Operation{
+			utils.Rename("Street.Name", "Street.Label"),
+			utils.Move("Owner", "Holder"),
+			utils.Drop("Legacy"),
+			utils.SetDefault("Port", 8080),
+		},
+	},
+}
+
+func TestOperations(t *testing.T) {
+	r := NewRegistry(nil)
+	if err := r.SetConfigVersionsStrict(opsVersions); err != nil {
+		t.Fatal(err)
+	}
+
+	v1 := opsV1{Version: 1, Street: opsStreetV1{Name: "Main", Number: 7}, Owner: "bob", Legacy: true}
+	up, err := r.MigrateUp(v1, opsV2{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := opsV2{Version: 2, Street: opsStreetV2{Label: "Main", Number: 7}, Holder: "bob", Port: 8080}
+	if got := *up.(*opsV2); got != want {
+		t.Fatalf("migrating up: got %#v, want %#v", got, want)
+	}
+
+	down, err := r.MigrateDown(up, opsV1{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the dropped field can't be restored
+	v1.Legacy = false
+	if got := *down.(*opsV1); got != v1 {
+		t.Fatalf("migrating down: got %#v, want %#v", got, v1)
+	}
+}
+
+type defaultV1 struct {
+	Version int
+	Port    int
+}
+
+func (c defaultV1) V() int { return 1 }
+
+type defaultV2 struct {
+	Version int
+	Port    int
+}
+
+func (c defaultV2) V() int { return 2 }
+
+func TestOperationsSetDefaultExistingField(t *testing.T) {
+	r := NewRegistry(nil)
+	err := r.SetConfigVersionsStrict([]utils.ConfigVersion{
+		{Config: defaultV1{}},
+		{Config: defaultV2{}, Ops: []utils.Operation{utils.SetDefault("Port", 8080)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	up, err := r.MigrateUp(defaultV1{Version: 1}, defaultV2{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port := up.(*defaultV2).Port; port != 8080 {
+		t.Fatalf("expected the default port, got %d", port)
+	}
+
+	// the field exists in both versions, migrating down keeps its value
+	down, err := r.MigrateDown(defaultV2{Version: 2, Port: 9000}, defaultV1{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port := down.(*defaultV1).Port; port != 9000 {
+		t.Fatalf("migrating down: expected port 9000, got %d", port)
+	}
+}
+
+func TestOperationsCustomMigrationPrecedence(t *testing.T) {
+	cv := []utils.ConfigVersion{
+		opsVersions[0],
+		{
+			Config: opsV2{},
+			Up:     utils.CustomMigration{"Port": func(c any) any { return 9090 }},
+			Ops:    opsVersions[1].Ops,
+		},
+	}
+	r := NewRegistry(cv)
+	up, err := r.MigrateUp(opsV1{Version: 1}, opsV2{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port := up.(*opsV2).Port; port != 9090 {
+		t.Fatalf("expected the custom migration to win, got port %d", port)
+	}
+}
+
+func TestValidateOperations(t *testing.T) {
+	cv := []utils.ConfigVersion{
+		{Config: opsV1{}},
+		{
+			Config: opsV2{},
+			Ops: []utils.Operation{
+				utils.Rename("Street.Nome", "Street.Label"),
+				utils.Move("Street", "Holder"),
+				utils.SetDefault("Port", "8080"),
+			},
+		},
+	}
+	err := validateVersions(cv)
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a *ValidationError, got %v", err)
+	}
+	// the three wrong ops migrating up and the two inverses migrating down
+	if len(verr.Problems) != 5 {
+		t.Fatalf("expected 5 problems, got:\n%v", verr)
+	}
+}
+
+type derivedV1 struct {
+	Version int
+	Timeout int
+	Host    string
+	Name    string
+}
+
+func (c derivedV1) V() int { return 1 }
+
+type derivedV2 struct {
+	Version int
+	Timeout float64
+	Hosts   []string
+	Label   string
+	Extra   string
+}
+
+func (c derivedV2) V() int { return 2 }
+
+var derivedVersions = []utils.ConfigVersion{
+	{Config: derivedV1{}},
+	{
+		Config: derivedV2{},
+		Ops: []utils.Operation{
+			utils.Scale("Timeout", 1000),
+			utils.Wrap("Host", "Hosts"),
+			utils.Rename("Name", "Label"),
+		},
+	},
+}
+
+func TestDerivedDown(t *testing.T) {
+	logger := &recordLogger{}
+	r := NewRegistry(nil)
+	if err := r.SetConfigVersionsStrict(derivedVersions); err != nil {
+		t.Fatal(err)
+	}
+	r.SetLogger(logger)
+
+	v1 := derivedV1{Version: 1, Timeout: 5, Host: "a", Name: "n"}
+	up, err := r.MigrateUp(v1, derivedV2{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2 := up.(*derivedV2)
+	if v2.Timeout != 5000 || !reflect.DeepEqual(v2.Hosts, []string{"a"}) || v2.Label != "n" {
+		t.Fatalf("unexpected migrated up config %#v", v2)
+	}
+
+	down, err := r.MigrateDown(v2, derivedV1{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := *down.(*derivedV1); got != v1 {
+		t.Fatalf("migrating down: got %#v, want %#v", got, v1)
+	}
+	if len(logger.events) != 2 {
+		t.Fatalf("unexpected events %v", logger.events)
+	}
+
+	// a field missing in the old version is lost
+	v2.Extra = "x"
+	if _, err := r.MigrateDown(v2, derivedV1{}); err != nil {
+		t.Fatal(err)
+	}
+	if last := logger.events[len(logger.events)-1]; last != "lossy migration step from=2 to=1 fields=[Extra]" {
+		t.Fatalf("expected the lossy step to be logged, got %v", logger.events)
+	}
+	r.SetStrictDown(true)
+	if _, err := r.MigrateDown(v2, derivedV1{}); err == nil || !strings.Contains(err.Error(), "Extra") {
+		t.Fatalf("expected lossy migration error, got %v", err)
+	}
+
+	// a DOWN migration only handles the field it sets, Extra is still reported
+	cv := append([]utils.ConfigVersion(nil), derivedVersions...)
+	cv[1].Down = utils.CustomMigration{"Name": func(c any) any { return c.(derivedV2).Label + c.(derivedV2).Extra }}
+	r.SetConfigVersions(cv)
+	var lossErr *LossyMigrationError
+	if _, err := r.MigrateDown(v2, derivedV1{}); !errors.As(err, &lossErr) || !reflect.DeepEqual(lossErr.Fields, []string{"Extra"}) {
+		t.Fatalf("expected a *LossyMigrationError on Extra, got %v", err)
+	}
+	r.SetStrictDown(false)
+	down, err = r.MigrateDown(v2, derivedV1{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := down.(*derivedV1).Name; name != "nx" {
+		t.Fatalf("unexpected name %q", name)
+	}
+
+	v2.Extra, v2.Hosts = "", []string{"a", "b"}
+	if _, err := r.MigrateDown(v2, derivedV1{}); err == nil || !strings.Contains(err.Error(), "2 elements") {
+		t.Fatalf("expected unwrap error, got %v", err)
+	}
+}
+
+func TestLossyScale(t *testing.T) {
+	logger := &recordLogger{}
+	r := NewRegistry(derivedVersions)
+	r.SetLogger(logger)
+
+	// 5500 milliseconds are rounded to 6 seconds
+	if _, err := r.MigrateDown(derivedV2{Version: 2, Timeout: 5500}, derivedV1{}); err != nil {
+		t.Fatal(err)
+	}
+	if last := logger.events[len(logger.events)-1]; last != "lossy migration step from=2 to=1 fields=[Timeout]" {
+		t.Fatalf("expected the lossy step to be logged, got %v", logger.events)
+	}
+	r.SetStrictDown(true)
+	if _, err := r.MigrateDown(derivedV2{Version: 2, Timeout: 5000}, derivedV1{}); err != nil {
+		t.Fatalf("expected an exact scale to be allowed, got %v", err)
+	}
+
+	// a DOWN migration of the field handles it
+	cv := append([]utils.ConfigVersion(nil), derivedVersions...)
+	cv[1].Down = utils.CustomMigration{"Timeout": func(c any) any { return int(math.Ceil(c.(derivedV2).Timeout / 1000)) }}
+	r.SetConfigVersions(cv)
+	down, err := r.MigrateDown(derivedV2{Version: 2, Timeout: 5500}, derivedV1{})
+	if err != nil || down.(*derivedV1).Timeout != 6 {
+		t.Fatalf("expected the custom migration to set the timeout, got %#v, %v", down, err)
+	}
+
+	// migrating up an integer scaled by 0.5 is rounded
+	err = validateVersions([]utils.ConfigVersion{
+		{Config: defaultV1{}},
+		{Config: defaultV2{}, Ops: []utils.Operation{utils.Scale("Port", 0.5)}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "rounds the integer field Port") {
+		t.Fatalf("expected the rounding scale to be reported, got %v", err)
+	}
+}
+
+type ptrInnerV1 struct{ B string }
+
+type ptrV1 struct {
+	Version int
+	Inner   *ptrInnerV1
+	Items   []ptrInnerV1
+}
+
+func (ptrV1) V() int { return 1 }
+
+type ptrInnerV2 struct{ C string }
+
+type ptrV2 struct {
+	Version int
+	Inner   *ptrInnerV2
+	Items   []ptrInnerV2
+}
+
+func (ptrV2) V() int { return 2 }
+
+func TestOperationsPointerPaths(t *testing.T) {
+	cv := []utils.ConfigVersion{
+		{Config: ptrV1{}},
+		{Config: ptrV2{}, Ops: []utils.Operation{utils.Rename("Inner.B", "Inner.C")}},
+	}
+	r := NewRegistry(nil)
+	if err := r.SetConfigVersionsStrict(cv); err != nil {
+		t.Fatal(err)
+	}
+	up, err := r.MigrateUp(ptrV1{Version: 1, Inner: &ptrInnerV1{B: "b"}}, ptrV2{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner := up.(*ptrV2).Inner; inner == nil || inner.C != "b" {
+		t.Fatalf("unexpected inner %#v", inner)
+	}
+	down, err := r.MigrateDown(up, ptrV1{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner := down.(*ptrV1).Inner; inner == nil || inner.B != "b" {
+		t.Fatalf("unexpected inner migrated down %#v", inner)
+	}
+	// a nil pointer is read as the zero value and stays nil
+	up, err = r.MigrateUp(ptrV1{Version: 1}, ptrV2{})
+	if err != nil || up.(*ptrV2).Inner != nil {
+		t.Fatalf("expected a nil inner, got %#v, %v", up, err)
+	}
+
+	// the elements can't be addressed, validating reports it as migrating would fail
+	cv[1].Ops = []utils.Operation{utils.Rename("Items[].B", "Items[].C")}
+	if err := validateVersions(cv); err == nil || !strings.Contains(err.Error(), "elements of slices") {
+		t.Fatalf("expected element paths to be rejected, got %v", err)
+	}
+}
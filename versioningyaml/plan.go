@@ -0,0 +1,326 @@
+package versioningyaml
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+)
+
+// MigrationStep is a migration between two consecutive registered versions
+type MigrationStep struct {
+	From, To int
+	// Custom are the keys of the custom migrations of the step, sorted
+	Custom []string
+	// Ops are the declarative operations of the step, the inverse ones migrating down
+	Ops []utils.Operation
+	// NodeMigration tells if the step is a node migration on the yaml tree
+	NodeMigration bool
+	// BeforeHook and AfterHook tell if the step has hooks
+	BeforeHook, AfterHook bool
+}
+
+func (st MigrationStep) String() string {
+	var parts []string
+	if len(st.Custom) > 0 {
+		parts = append(parts, "custom "+strings.Join(st.Custom, ", "))
+	}
+	if len(st.Ops) > 0 {
+		ops := make([]string, len(st.Ops))
+		for i, op := range st.Ops {
+			ops[i] = op.String()
+		}
+		parts = append(parts, "ops "+strings.Join(ops, ", "))
+	}
+	if st.NodeMigration {
+		parts = append(parts, "node migration")
+	}
+	if st.BeforeHook {
+		parts = append(parts, "before hook")
+	}
+	if st.AfterHook {
+		parts = append(parts, "after hook")
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "copy")
+	}
+	return fmt.Sprintf("v%d -> v%d: %s", st.From, st.To, strings.Join(parts, "; "))
+}
+
+// MigrationPlan is the ordered list of steps to migrate from a version to another
+type MigrationPlan struct {
+	From, To int
+	Steps    []MigrationStep
+}
+
+func (p *MigrationPlan) String() string {
+	lines := []string{fmt.Sprintf("migration from version %d to %d, %d steps", p.From, p.To, len(p.Steps))}
+	for _, st := range p.Steps {
+		lines = append(lines, "  "+st.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PlanMigration returns the steps to migrate from version [from] to version [to]
+// using the default registry, see Registry.PlanMigration
+func PlanMigration(from, to int) (*MigrationPlan, error) {
+	return defaultRegistry.PlanMigration(from, to)
+}
+
+// PlanMigration returns the steps to migrate from version [from] to version [to] in the order
+// they are applied, UP or DOWN depending on the order of the versions, without applying them
+func (r *Registry) PlanMigration(from, to int) (*MigrationPlan, error) {
+	plan, _, _, err := r.snapshot().planMigration(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("planning migration: %w", err)
+	}
+	return plan, nil
+}
+
+// planMigration returns the plan from version [from] to [to] and their indexes
+func (s *snapshot) planMigration(from, to int) (*MigrationPlan, int, int, error) {
+	_, iFrom := s.findByVersion(from)
+	if iFrom < 0 {
+		return nil, 0, 0, fmt.Errorf("version %d is not registered", from)
+	}
+	_, iTo := s.findByVersion(to)
+	if iTo < 0 {
+		return nil, 0, 0, fmt.Errorf("version %d is not registered", to)
+	}
+
+	plan := &MigrationPlan{From: from, To: to}
+	for i := iFrom; i != iTo; {
+		next := i + 1
+		if iTo < iFrom {
+			next = i - 1
+		}
+		st := s.step(i, next)
+		custom := keysOf(st.migration)
+		sort.Strings(custom)
+		plan.Steps = append(plan.Steps, MigrationStep{
+			From:          versionNumber(s.configVersions[i]),
+			To:            versionNumber(s.configVersions[next]),
+			Custom:        custom,
+			Ops:           st.ops,
+			NodeMigration: st.nodeMigration != nil,
+			BeforeHook:    st.before != nil,
+			AfterHook:     st.after != nil,
+		})
+		i = next
+	}
+	return plan, iFrom, iTo, nil
+}
+
+// FieldAction is what a migration step does to a field
+type FieldAction int
+
+const (
+	// FieldCopied is a field copied as is from the same field of the version before
+	FieldCopied FieldAction = iota
+	// FieldConverted is a field copied from the same field of the version before with a different type
+	FieldConverted
+	// FieldCustom is a field set by a custom migration
+	FieldCustom
+	// FieldOperation is a field set by a declarative operation
+	FieldOperation
+	// FieldDefaulted is a new field left to its zero value
+	FieldDefaulted
+	// FieldDropped is a field of the version before missing in the new one
+	FieldDropped
+)
+
+func (a FieldAction) String() string {
+	switch a {
+	case FieldCopied:
+		return "copied"
+	case FieldConverted:
+		return "converted"
+	case FieldCustom:
+		return "custom"
+	case FieldOperation:
+		return "operation"
+	case FieldDefaulted:
+		return "defaulted"
+	case FieldDropped:
+		return "dropped"
+	}
+	return fmt.Sprintf("FieldAction(%d)", int(a))
+}
+
+// FieldReport is what a migration step does to the field with dotted path Path
+type FieldReport struct {
+	Path   string
+	Action FieldAction
+	// Detail are the types of a converted field or the operation setting it
+	Detail string
+}
+
+// StepReport is the report of a migration step, Fields is empty for node migrations
+type StepReport struct {
+	MigrationStep
+	Fields []FieldReport
+	// Lost are the fields whose values are lost by a DOWN step, see Registry.SetStrictDown
+	Lost []string
+}
+
+// MigrationReport is the result of a dry run of a migration
+type MigrationReport struct {
+	From, To int
+	Steps    []StepReport
+	// Result is a pointer to the migrated config, nil if the target version is retired
+	Result utils.Config
+}
+
+func (r *MigrationReport) String() string {
+	lines := []string{fmt.Sprintf("migration from version %d to %d", r.From, r.To)}
+	for _, st := range r.Steps {
+		lines = append(lines, "  "+st.MigrationStep.String())
+		for _, f := range st.Fields {
+			line := fmt.Sprintf("    %-10s %s", f.Action, f.Path)
+			if f.Detail != "" {
+				line += " (" + f.Detail + ")"
+			}
+			lines = append(lines, line)
+		}
+		for _, path := range st.Lost {
+			lines = append(lines, fmt.Sprintf("    %-10s %s", "lost", path))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DryRunMigration migrates [source] to version [to] using the default registry,
+// see Registry.DryRunMigration
+func DryRunMigration(source utils.Config, to int) (*MigrationReport, error) {
+	return defaultRegistry.DryRunMigration(source, to)
+}
+
+// DryRunMigration migrates [source] to version [to] as MigrateTo does, without modifying [source],
+// and reports for every step what is done to every field: copied, converted, custom migrated,
+// set by an operation, defaulted to the zero value or dropped.
+// Hooks may change any field, the steps report if they have them
+func (r *Registry) DryRunMigration(source utils.Config, to int) (*MigrationReport, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("dry run of migration: %w", err)
+	}
+	if isNil(source) {
+		return nil, wrapErr(errors.New("source is nil"))
+	}
+	s := r.snapshot()
+	plan, iFrom, iTo, err := s.planMigration(source.V(), to)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+
+	// the lost fields are reported instead of failing the dry run
+	lost := map[[2]int][]string{}
+	dry := *s
+	dry.strictDown = false
+	dry.onLoss = func(from, to int, fields []string) { lost[[2]int{from, to}] = fields }
+	migrated, err := dry.migrate(context.Background(), source, iFrom, iTo)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	report := &MigrationReport{From: plan.From, To: plan.To}
+	if iFrom == iTo {
+		// s.migrate returns source itself, the result must not alias it
+		migrated = copyConfig(source)
+	}
+	if config, ok := migrated.(utils.Config); ok {
+		report.Result = config
+	}
+
+	for n, st := range plan.Steps {
+		i := iFrom + n
+		next := i + 1
+		if iTo < iFrom {
+			i, next = iFrom-n, iFrom-n-1
+		}
+		stepReport := StepReport{MigrationStep: st, Lost: lost[[2]int{st.From, st.To}]}
+		if !st.NodeMigration {
+			stepReport.Fields = fieldReports(configType(s.configVersions[i]), configType(s.configVersions[next]), s.step(i, next))
+		}
+		report.Steps = append(report.Steps, stepReport)
+	}
+	return report, nil
+}
+
+// configType returns the type of the Config struct of [cv], nil if it is retired
+func configType(cv utils.ConfigVersion) reflect.Type {
+	if cv.Config == nil {
+		return nil
+	}
+	return reflect.TypeOf(cv.Config)
+}
+
+// fieldReports returns what the step [st] does to the fields migrating from [sourceType] to [destType]
+func fieldReports(sourceType, destType reflect.Type, st step) []FieldReport {
+	if sourceType == nil || destType == nil {
+		return nil
+	}
+	sourcePaths, sourceTypes := leafFields(sourceType)
+	destPaths, destTypes := leafFields(destType)
+
+	opByPath := map[string]utils.Operation{}
+	moved := map[string]bool{}
+	for _, op := range st.ops {
+		if _, ok := st.migration[op.Path()]; !ok {
+			opByPath[op.Path()] = op
+		}
+		if op.Kind != utils.DropOp && op.Kind != utils.SetDefaultOp {
+			moved[op.From] = true
+		}
+	}
+
+	var reports []FieldReport
+	for _, path := range destPaths {
+		if path == "Version" {
+			continue
+		}
+		sourceType, inSource := sourceTypes[path]
+		_, custom := st.migration[path]
+		op, isOp := opByPath[path]
+		switch {
+		case custom:
+			reports = append(reports, FieldReport{Path: path, Action: FieldCustom})
+		case isOp && op.Kind == utils.DropOp:
+			reports = append(reports, FieldReport{Path: path, Action: FieldDefaulted, Detail: op.String()})
+		case isOp:
+			reports = append(reports, FieldReport{Path: path, Action: FieldOperation, Detail: op.String()})
+		case !inSource:
+			reports = append(reports, FieldReport{Path: path, Action: FieldDefaulted})
+		case sourceType == destTypes[path]:
+			reports = append(reports, FieldReport{Path: path, Action: FieldCopied})
+		default:
+			reports = append(reports, FieldReport{Path: path, Action: FieldConverted, Detail: fmt.Sprintf("%v -> %v", sourceType, destTypes[path])})
+		}
+	}
+
+	// custom migrations of the elements of slices and maps
+	var elemKeys []string
+	for key := range st.migration {
+		if strings.Contains(key, "[]") {
+			elemKeys = append(elemKeys, key)
+		}
+	}
+	sort.Strings(elemKeys)
+	for _, key := range elemKeys {
+		reports = append(reports, FieldReport{Path: key, Action: FieldCustom})
+	}
+
+	for _, path := range sourcePaths {
+		if _, ok := destTypes[path]; ok || moved[path] || path == "Version" {
+			continue
+		}
+		report := FieldReport{Path: path, Action: FieldDropped}
+		if op, ok := opByPath[path]; ok && op.Kind == utils.DropOp {
+			report.Detail = op.String()
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
@@ -0,0 +1,107 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	versions "github.com/davide-camponogara/versioningyaml/versions_test"
+)
+
+func TestPlanMigration(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+
+	plan, err := r.PlanMigration(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "migration from version 1 to 3, 2 steps\n" +
+		"  v1 -> v2: custom City\n" +
+		"  v2 -> v3: custom Street.Name, TestV3"
+	if plan.String() != want {
+		t.Fatalf("got plan:\n%v\nwant:\n%v", plan, want)
+	}
+
+	plan, err = r.PlanMigration(3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Steps) != 2 || plan.Steps[0].String() != "v3 -> v2: custom City" || plan.Steps[1].String() != "v2 -> v1: copy" {
+		t.Fatalf("unexpected plan:\n%v", plan)
+	}
+
+	if _, err := r.PlanMigration(1, 4); err == nil {
+		t.Fatal("expected error planning to an unknown version")
+	}
+}
+
+func TestDryRunMigration(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+	source := versions.ConfigV1{Version: 1, Street: versions.Street{Field1: 7, Name: "Main"}, City: "Rome", ZipCode: 100}
+	report, err := r.DryRunMigration(source, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actions := func(st StepReport) string {
+		var a []string
+		for _, f := range st.Fields {
+			a = append(a, f.Action.String()+" "+f.Path)
+		}
+		return strings.Join(a, ", ")
+	}
+	if got := actions(report.Steps[0]); got != "copied Street.Field1, copied Street.Name, custom City, defaulted Test, dropped ZipCode" {
+		t.Fatalf("unexpected first step: %s", got)
+	}
+	if got := actions(report.Steps[1]); got != "copied Street.Field1, custom Street.Name, copied City, custom TestV3, defaulted TestV3_2, copied Test" {
+		t.Fatalf("unexpected second step: %s", got)
+	}
+
+	want := versions.ConfigV3{Version: 3, Street: versions.Street{Field1: 7, Name: "7 Main"}, City: "Rome Main", TestV3: 7}
+	if got := report.Result.(*versions.ConfigV3); !reflect.DeepEqual(*got, want) {
+		t.Fatalf("got %#v, want %#v", *got, want)
+	}
+	if source.City != "Rome" {
+		t.Fatal("the source was modified")
+	}
+}
+
+func TestDryRunMigrationSameVersion(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+	if _, err := r.DryRunMigration(nil, 3); err == nil {
+		t.Fatal("expected error for a nil source")
+	}
+	if _, err := r.DryRunMigration((*versions.ConfigV1)(nil), 3); err == nil {
+		t.Fatal("expected error for a nil pointer source")
+	}
+
+	source := &versions.ConfigV1{Version: 1, City: "Rome"}
+	report, err := r.DryRunMigration(source, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := report.Result.(*versions.ConfigV1)
+	if result == source || *result != *source {
+		t.Fatalf("expected a copy of the source, got %#v", result)
+	}
+	result.City = "Milan"
+	if source.City != "Rome" {
+		t.Fatal("the source was modified through the result")
+	}
+}
+
+func TestDryRunMigrationLost(t *testing.T) {
+	r := NewRegistry(derivedVersions)
+	r.SetStrictDown(true)
+
+	// the dry run reports the lost fields instead of failing
+	report, err := r.DryRunMigration(derivedV2{Version: 2, Timeout: 5000, Extra: "x"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.Steps[0].Lost, []string{"Extra"}) || !strings.Contains(report.String(), "    lost       Extra") {
+		t.Fatalf("expected Extra to be reported as lost, got\n%s", report)
+	}
+}
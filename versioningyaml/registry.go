@@ -0,0 +1,181 @@
+package versioningyaml
+
+import (
+	"sync"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+)
+
+// Registry holds everything needed to load, migrate and write one family of
+// versioned yaml files: the ordered list of versions, the long comments, the
+// indentation and the default version.
+//
+// Different registries are independent, so more config families can coexist
+// in the same binary. The package level functions work on a default registry.
+//
+// A Registry is safe for concurrent use: every operation works on an immutable
+// snapshot of the settings taken when it starts, so reconfiguring the registry
+// doesn't affect loadings and migrations already running.
+type Registry struct {
+	mu    sync.RWMutex
+	state *snapshot
+}
+
+// snapshot is an immutable copy of the registry settings,
+// it is never modified after being published in a Registry
+type snapshot struct {
+	// configVersions contains an ordered list of the versions of the yaml file
+	configVersions []utils.ConfigVersion
+	// longComments is a map containing long comments
+	longComments map[string]string
+	// indent is the indendtation spaces
+	indent int
+	// defaultVersion is the version of a .yaml where the field "version" is not present
+	defaultVersion int
+	// logger receives the events of the registry
+	logger Logger
+	// strictDown makes the DOWN steps that would lose values fail instead of logging them
+	strictDown bool
+	// onLoss, if set, receives the fields lost by every DOWN step (see DryRunMigration)
+	onLoss func(from, to int, lost []string)
+}
+
+// NewRegistry returns a registry for the ordered versions [cv] with
+// indentation 3 and default version 1
+func NewRegistry(cv []utils.ConfigVersion) *Registry {
+	return &Registry{
+		state: &snapshot{
+			configVersions: copyVersions(cv),
+			indent:         3,
+			defaultVersion: 1,
+			logger:         nopLogger{},
+		},
+	}
+}
+
+// snapshot returns the current settings of the registry
+func (r *Registry) snapshot() *snapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.state
+}
+
+// update publishes a modified copy of the current settings
+func (r *Registry) update(f func(s *snapshot)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := *r.state
+	f(&s)
+	r.state = &s
+}
+
+// copyVersions copies the list of versions so that later changes made by the caller
+// to its slice are not seen by the registry
+func copyVersions(cv []utils.ConfigVersion) []utils.ConfigVersion {
+	if cv == nil {
+		return nil
+	}
+	return append([]utils.ConfigVersion(nil), cv...)
+}
+
+// copyComments copies the long comments for the same reason of copyVersions
+func copyComments(lc map[string]string) map[string]string {
+	if lc == nil {
+		return nil
+	}
+	c := make(map[string]string, len(lc))
+	for k, v := range lc {
+		c[k] = v
+	}
+	return c
+}
+
+// defaultRegistry is the registry used by the package level functions
+var defaultRegistry = NewRegistry(nil)
+
+// SetConfigVersions setter for ConfigVersions
+//
+// ConfigVersions contains an ordered list of the versions of the yaml file
+// every entry is a ConfigVersion object that contains:
+//   - the Config struct
+//   - the CustomMigration UP
+//   - the CustomMigration DOWN
+//
+// the custom migrations are unes when one have to manipulate the fileds instead
+// if a field doesn't change from the version before or is one is a new (standalone) field a CustomMigration is not needed
+func (r *Registry) SetConfigVersions(cv []utils.ConfigVersion) {
+	cv = copyVersions(cv)
+	r.update(func(s *snapshot) { s.configVersions = cv })
+}
+
+// SetDefaultVersion setter for defaultVersion
+//
+// defaultVersion contains the default version of a .yaml where the field "version" is not present
+func (r *Registry) SetDefaultVersion(defVersion int) {
+	r.update(func(s *snapshot) { s.defaultVersion = defVersion })
+}
+
+// SetIndent setter for indent (default 3)
+func (r *Registry) SetIndent(indentation int) {
+	r.update(func(s *snapshot) { s.indent = indentation })
+}
+
+// SetLongComments setter for LongComments
+//
+// LongComments is a map containing long comments
+// by convenction a reference to a long comment is denoted with a $ in form of the name
+func (r *Registry) SetLongComments(lc map[string]string) {
+	lc = copyComments(lc)
+	r.update(func(s *snapshot) { s.longComments = lc })
+}
+
+// SetStrictDown setter for strictDown (default false)
+//
+// A DOWN step is checked field by field: the values of the fields that don't exist in the older version,
+// that are reset by the inverse operations or that Scale can't restore are lost, unless a CustomMigration
+// DOWN sets the field (a field with the same path). Steps with a node migration or an AfterDown hook,
+// that can restore anything, are not checked.
+// With strictDown a lossy step fails with a *LossyMigrationError, otherwise it is logged as
+// "lossy migration step". DryRunMigration reports the lost fields in any case
+func (r *Registry) SetStrictDown(strict bool) {
+	r.update(func(s *snapshot) { s.strictDown = strict })
+}
+
+// SetConfigVersions setter for ConfigVersions of the default registry
+//
+// ConfigVersions contains an ordered list of the versions of the yaml file
+// every entry is a ConfigVersion object that contains:
+//   - the Config struct
+//   - the CustomMigration UP
+//   - the CustomMigration DOWN
+//
+// the custom migrations are unes when one have to manipulate the fileds instead
+// if a field doesn't change from the version before or is one is a new (standalone) field a CustomMigration is not needed
+func SetConfigVersions(cv []utils.ConfigVersion) {
+	defaultRegistry.SetConfigVersions(cv)
+}
+
+// SetDefaultVersion setter for defaultVersion of the default registry
+//
+// defaultVersion contains the default version of a .yaml where the field "version" is not present
+func SetDefaultVersion(defVersion int) {
+	defaultRegistry.SetDefaultVersion(defVersion)
+}
+
+// SetIndent setter for indent of the default registry (default 3)
+func SetIndent(indentation int) {
+	defaultRegistry.SetIndent(indentation)
+}
+
+// SetLongComments setter for LongComments of the default registry
+//
+// LongComments is a map containing long comments
+// by convenction a reference to a long comment is denoted with a $ in form of the name
+func SetLongComments(lc map[string]string) {
+	defaultRegistry.SetLongComments(lc)
+}
+
+// SetStrictDown setter for strictDown of the default registry (default false), see Registry.SetStrictDown
+func SetStrictDown(strict bool) {
+	defaultRegistry.SetStrictDown(strict)
+}
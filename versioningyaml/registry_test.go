@@ -0,0 +1,81 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	versions "github.com/davide-camponogara/versioningyaml/versions_test"
+)
+
+// TestConcurrentUse must be run with -race to be meaningful
+func TestConcurrentUse(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+	r.SetLongComments(versions.LongComments)
+	dir := t.TempDir()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				config, _, err := r.LoadConfigVersioned("testdata/configv2.yaml")
+				if err != nil {
+					errs <- err
+					return
+				}
+				c, err := r.MigrateUp(config, &versions.ConfigV3{})
+				if err != nil {
+					errs <- err
+					return
+				}
+				path := filepath.Join(dir, fmt.Sprintf("config_%d.yaml", g))
+				if err := r.WriteYaml(*c.(*versions.ConfigV3), path); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(g)
+	}
+
+	// reconfigure the registry while the other goroutines are using it
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			r.SetConfigVersions(versions.ConfigVersions)
+			r.SetLongComments(versions.LongComments)
+			r.SetIndent(2 + i%3)
+			r.SetDefaultVersion(1)
+		}
+	}()
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestSettersCopyArguments(t *testing.T) {
+	cv := append(versions.ConfigVersions[:0:0], versions.ConfigVersions...)
+	lc := map[string]string{"$comm1": "first"}
+	r := NewRegistry(cv)
+	r.SetLongComments(lc)
+
+	// changes made by the caller after setting must not be seen by the registry
+	cv[2] = cv[0]
+	lc["$comm1"] = "second"
+
+	if _, i := r.snapshot().findByVersion(3); i != 2 {
+		t.Fatalf("expected version 3 at index 2, got %d", i)
+	}
+	if c := r.snapshot().longComments["$comm1"]; c != "first" {
+		t.Fatalf("expected long comment to be copied, got %q", c)
+	}
+}
@@ -0,0 +1,246 @@
+package versioningyaml
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+)
+
+// schemaDialect is the JSON Schema version of the generated schemas
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// configFormatter is implemented by the types rendered with their Config() method
+var configFormatter = reflect.TypeOf((*interface{ Config() string })(nil)).Elem()
+
+// JSONSchema returns the JSON Schema of the yaml files of [version] using the default registry,
+// see Registry.JSONSchema
+func JSONSchema(version int) ([]byte, error) {
+	return defaultRegistry.JSONSchema(version)
+}
+
+// JSONSchema returns the JSON Schema of the yaml files of the registered [version], generated from its Config struct:
+//   - properties are named as the yaml keys and have as description the comment and lineComment tags,
+//     with the long comments resolved
+//   - nested structs are objects that don't allow unknown keys, maps are objects and slices arrays
+//     (fields tagged as short are written in json format but have the same schema)
+//   - pointers can also be null, types with a Config() method can be any value
+//   - fields are required unless they are tagged omitempty or are pointers, see isRequired
+//   - the version field must be [version], it is required unless version is the default version
+func (r *Registry) JSONSchema(version int) ([]byte, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("generating json schema of version %d: %w", version, err)
+	}
+	s := r.snapshot()
+	cv, i := s.findByVersion(version)
+	if i < 0 {
+		return nil, wrapErr(fmt.Errorf("version %d is not registered", version))
+	}
+	if cv.Config == nil {
+		return nil, wrapErr(fmt.Errorf("version %d is retired and has no Config struct", version))
+	}
+
+	g := newSchemaGenerator(s)
+	schema := g.versionSchema(*cv)
+	schema["$schema"] = schemaDialect
+	g.addDefs(schema)
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// JSONSchemaAll returns the JSON Schema of the yaml files of any version using the default registry,
+// see Registry.JSONSchemaAll
+func JSONSchemaAll() ([]byte, error) {
+	return defaultRegistry.JSONSchemaAll()
+}
+
+// JSONSchemaAll returns the JSON Schema of the yaml files of any registered version: a oneOf of the schemas
+// of every version (see JSONSchema) discriminated by the version field.
+// Retired versions only check the version field
+func (r *Registry) JSONSchemaAll() ([]byte, error) {
+	s := r.snapshot()
+	if len(s.configVersions) == 0 {
+		return nil, fmt.Errorf("generating json schema: no config versions registered")
+	}
+	g := newSchemaGenerator(s)
+	versions := make([]any, 0, len(s.configVersions))
+	for _, cv := range s.configVersions {
+		versions = append(versions, g.versionSchema(cv))
+	}
+	schema := map[string]any{
+		"$schema": schemaDialect,
+		"title":   "config",
+		"oneOf":   versions,
+	}
+	g.addDefs(schema)
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// schemaGenerator generates the schemas of the types of a registry. Recursive struct types
+// (a struct reachable from its own fields) are generated once in $defs and referenced with $ref
+type schemaGenerator struct {
+	*snapshot
+	// defs are the schemas of the recursive types by name
+	defs map[string]any
+	// inProgress are the struct types being generated, recursive the ones found again while generating them
+	inProgress, recursive map[reflect.Type]bool
+}
+
+func newSchemaGenerator(s *snapshot) *schemaGenerator {
+	return &schemaGenerator{snapshot: s, defs: map[string]any{}, inProgress: map[reflect.Type]bool{}, recursive: map[reflect.Type]bool{}}
+}
+
+// addDefs adds to the root [schema] the schemas of the recursive types, if any
+func (g *schemaGenerator) addDefs(schema map[string]any) {
+	if len(g.defs) > 0 {
+		schema["$defs"] = g.defs
+	}
+}
+
+// versionSchema returns the schema of the yaml files of [cv]
+func (g *schemaGenerator) versionSchema(cv utils.ConfigVersion) map[string]any {
+	version := versionNumber(cv)
+	versionSchema := map[string]any{"const": version}
+
+	key := "version"
+	var schema map[string]any
+	if cv.Config == nil {
+		schema = map[string]any{
+			"type":        "object",
+			"description": fmt.Sprintf("retired version %d", version),
+			"properties":  map[string]any{key: versionSchema},
+		}
+	} else {
+		t := reflect.TypeOf(cv.Config)
+		if field, ok := t.FieldByName("Version"); ok {
+			key = yamlKey(field)
+		}
+		// the root is always inline, even if its fields reference it
+		schema = g.structSchema(t)
+		if g.recursive[t] {
+			g.defs[defName(t)] = g.structSchema(t)
+		}
+		schema["properties"].(map[string]any)[key] = versionSchema
+	}
+	schema["title"] = fmt.Sprintf("config version %d", version)
+
+	if version != g.defaultVersion {
+		required, _ := schema["required"].([]string)
+		schema["required"] = append([]string{key}, required...)
+	}
+	return schema
+}
+
+// isRequired tells if the field must be present in the yaml file: it is not the version field
+// (missing for the default version), it is not a pointer (can be null) and isn't tagged omitempty
+func isRequired(field reflect.StructField) bool {
+	if field.Name == "Version" || field.Type.Kind() == reflect.Ptr {
+		return false
+	}
+	for _, option := range strings.Split(field.Tag.Get("yaml"), ",")[1:] {
+		if option == "omitempty" {
+			return false
+		}
+	}
+	return true
+}
+
+// typeSchema returns the schema of the values of type [t] in the yaml file
+func (g *schemaGenerator) typeSchema(t reflect.Type) map[string]any {
+	if t.Implements(configFormatter) || reflect.PtrTo(t).Implements(configFormatter) {
+		return map[string]any{}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return map[string]any{"anyOf": []any{g.typeSchema(t.Elem()), map[string]any{"type": "null"}}}
+	case reflect.Struct:
+		return g.structRef(t)
+	case reflect.Map:
+		schema := map[string]any{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+		if isInteger(t.Key()) {
+			schema["propertyNames"] = map[string]any{"pattern": "^-?[0-9]+$"}
+		}
+		return schema
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Array:
+		return map[string]any{"type": "array", "items": g.typeSchema(t.Elem()), "maxItems": t.Len()}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	}
+	return map[string]any{}
+}
+
+// structRef returns the schema of the struct type [t], a $ref to its definition if it is recursive
+func (g *schemaGenerator) structRef(t reflect.Type) map[string]any {
+	ref := map[string]any{"$ref": "#/$defs/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(defName(t))}
+	if g.inProgress[t] || g.recursive[t] {
+		g.recursive[t] = true
+		return ref
+	}
+	schema := g.structSchema(t)
+	if g.recursive[t] {
+		g.defs[defName(t)] = schema
+		return ref
+	}
+	return schema
+}
+
+// defName returns the name of the definition of the type [t] in $defs
+func defName(t reflect.Type) string {
+	return t.String()
+}
+
+// structSchema returns the schema of the struct type [t]
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]any {
+	g.inProgress[t] = true
+	defer delete(g.inProgress, t)
+
+	properties := map[string]any{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := yamlKey(field)
+		if field.PkgPath != "" || key == "-" {
+			continue
+		}
+		schema := g.typeSchema(field.Type)
+		if description := g.fieldDescription(field); description != "" {
+			schema["description"] = description
+		}
+		properties[key] = schema
+		if isRequired(field) {
+			required = append(required, key)
+		}
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// fieldDescription returns the comment and lineComment tags of [field] resolving the long comments
+func (s *snapshot) fieldDescription(field reflect.StructField) string {
+	var lines []string
+	for _, tag := range []string{"comment", "lineComment"} {
+		comment := field.Tag.Get(tag)
+		if long, ok := s.longComments[comment]; ok {
+			comment = long
+		}
+		if comment != "" {
+			lines = append(lines, comment)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,156 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+	versions "github.com/davide-camponogara/versioningyaml/versions_test"
+)
+
+type schemaConfig struct {
+	Version int               `yaml:"version"`
+	Name    *string           `yaml:"name"`
+	Tags    []string          `yaml:"tags,omitempty" comment:"the tags"`
+	Ports   map[string]uint16 `yaml:"ports"`
+	hidden  int
+}
+
+func (schemaConfig) V() int { return 1 }
+
+func TestJSONSchema(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+	r.SetLongComments(versions.LongComments)
+
+	out, err := r.JSONSchema(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(out, &schema); err != nil {
+		t.Fatal(err)
+	}
+	properties := schema["properties"].(map[string]any)
+	street := properties["street"].(map[string]any)
+	if street["description"] != "Test commento numeor 1" || street["additionalProperties"] != false {
+		t.Fatalf("unexpected street schema %v", street)
+	}
+	name := street["properties"].(map[string]any)["Name"].(map[string]any)
+	if name["type"] != "string" || name["description"] != "ciao prova indentazione\ntest bello\nprova line" {
+		t.Fatalf("unexpected street name schema %v", name)
+	}
+	test := properties["test"].(map[string]any)
+	if test["type"] != "object" || test["propertyNames"] == nil || test["additionalProperties"].(map[string]any)["type"] != "boolean" {
+		t.Fatalf("unexpected short map schema %v", test)
+	}
+	if properties["version"].(map[string]any)["const"] != 3.0 || properties["testv3"].(map[string]any)["type"] != "number" {
+		t.Fatalf("unexpected properties %v", properties)
+	}
+	wantRequired := []any{"version", "street", "city", "testv3", "testv3_2", "test"}
+	if !reflect.DeepEqual(schema["required"], wantRequired) {
+		t.Fatalf("got required %v, want %v", schema["required"], wantRequired)
+	}
+
+	// the version field is not required in the default version
+	out, err = r.JSONSchema(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema = nil
+	if err := json.Unmarshal(out, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(schema["required"], []any{"street", "city", "zipcode"}) {
+		t.Fatalf("unexpected required %v", schema["required"])
+	}
+
+	if _, err := r.JSONSchema(4); err == nil {
+		t.Fatal("expected error for an unknown version")
+	}
+}
+
+func TestJSONSchemaTypes(t *testing.T) {
+	r := NewRegistry(nil)
+	schema := newSchemaGenerator(r.snapshot()).typeSchema(reflect.TypeOf(schemaConfig{}))
+	out, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"additionalProperties":false,"properties":{` +
+		`"name":{"anyOf":[{"type":"string"},{"type":"null"}]},` +
+		`"ports":{"additionalProperties":{"minimum":0,"type":"integer"},"type":"object"},` +
+		`"tags":{"description":"the tags","items":{"type":"string"},"type":"array"},` +
+		`"version":{"type":"integer"}},` +
+		`"required":["ports"],"type":"object"}`
+	if string(out) != want {
+		t.Fatalf("got schema\n%s\nwant\n%s", out, want)
+	}
+}
+
+type schemaNode struct {
+	Name string      `yaml:"name"`
+	Next *schemaNode `yaml:"next"`
+}
+
+type schemaRecursive struct {
+	Version int          `yaml:"version"`
+	Head    schemaNode   `yaml:"head"`
+	Others  []schemaNode `yaml:"others"`
+}
+
+func (schemaRecursive) V() int { return 1 }
+
+func TestJSONSchemaRecursive(t *testing.T) {
+	r := NewRegistry([]utils.ConfigVersion{{Config: schemaRecursive{}}})
+	out, err := r.JSONSchema(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Defs       map[string]map[string]any `json:"$defs"`
+		Properties map[string]map[string]any `json:"properties"`
+	}
+	if err := json.Unmarshal(out, &schema); err != nil {
+		t.Fatal(err)
+	}
+	ref := "#/$defs/versioningyaml.schemaNode"
+	if schema.Properties["head"]["$ref"] != ref || schema.Defs["versioningyaml.schemaNode"] == nil {
+		t.Fatalf("expected the recursive type in $defs, got %s", out)
+	}
+	next := schema.Defs["versioningyaml.schemaNode"]["properties"].(map[string]any)["next"].(map[string]any)
+	if next["anyOf"].([]any)[0].(map[string]any)["$ref"] != ref {
+		t.Fatalf("expected next to reference its own type, got %v", next)
+	}
+}
+
+func TestJSONSchemaAll(t *testing.T) {
+	r := NewRegistry(retiredVersions)
+	out, err := r.JSONSchemaAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		OneOf []struct {
+			Description string                    `json:"description"`
+			Properties  map[string]map[string]any `json:"properties"`
+			Required    []string                  `json:"required"`
+		} `json:"oneOf"`
+	}
+	if err := json.Unmarshal(out, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if len(schema.OneOf) != 3 {
+		t.Fatalf("expected 3 versions, got %s", out)
+	}
+	for i, v := range schema.OneOf {
+		if v.Properties["version"]["const"] != float64(i+1) {
+			t.Fatalf("version %d not discriminated: %v", i+1, v.Properties["version"])
+		}
+	}
+	if schema.OneOf[0].Description != "retired version 1" || len(schema.OneOf[0].Required) != 0 || schema.OneOf[1].Required[0] != "version" {
+		t.Fatalf("unexpected schemas %s", out)
+	}
+}
@@ -0,0 +1,103 @@
+package versioningyaml
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UpgradeOptions configures UpgradeFile
+type UpgradeOptions struct {
+	// WriteOptions used writing the upgraded file
+	WriteOptions
+	// DryRun doesn't write the file, the upgraded yaml is only returned in UpgradeResult.Content
+	DryRun bool
+	// PreserveComments keeps comments, order and unknown keys of the original file
+	// for the fields not changed by the migration, see Registry.MarshalPreserving
+	PreserveComments bool
+}
+
+// UpgradeResult reports what UpgradeFile did
+type UpgradeResult struct {
+	// From is the version of the file before the upgrade
+	From int
+	// To is the version of the file after the upgrade (the newest registered version)
+	To int
+	// Upgraded is false when the file was already at the newest version and was left untouched
+	Upgraded bool
+	// Content is the upgraded yaml, written to the file unless DryRun is set
+	Content []byte
+}
+
+// UpgradeFile migrates the config file [path] to the newest version of the default registry
+// and writes it back, see Registry.UpgradeFile
+func UpgradeFile(path string, opts UpgradeOptions) (UpgradeResult, error) {
+	return defaultRegistry.UpgradeFile(path, opts)
+}
+
+// UpgradeFile migrates the config file [path] to the newest registered version and writes it back
+// as WriteYaml does. A file already at the newest version is not rewritten.
+// With DryRun the file is not written and the would-be content is returned in the result
+func (r *Registry) UpgradeFile(path string, opts UpgradeOptions) (UpgradeResult, error) {
+	return r.snapshot().upgradeFile(path, opts)
+}
+
+func (s *snapshot) upgradeFile(path string, opts UpgradeOptions) (UpgradeResult, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("upgrading %v: %w", path, err)
+	}
+	if len(s.configVersions) == 0 {
+		return UpgradeResult{}, wrapErr(errors.New("no config versions registered"))
+	}
+	last := len(s.configVersions) - 1
+
+	content, err := s.readFile(path)
+	if err != nil {
+		return UpgradeResult{}, wrapErr(err)
+	}
+	decoded, version, err := s.decode(content)
+	if err != nil {
+		return UpgradeResult{}, wrapErr(err)
+	}
+	result := UpgradeResult{From: version, To: versionNumber(s.configVersions[last])}
+	if result.From == result.To {
+		return result, nil
+	}
+
+	_, from := s.findByVersion(version)
+	migrated, err := s.migrate(context.Background(), decoded, from, last)
+	if err != nil {
+		return UpgradeResult{}, wrapErr(err)
+	}
+	config, ok := migrated.(utils.Config)
+	if !ok {
+		return UpgradeResult{}, wrapErr(fmt.Errorf("version %d is retired and has no Config struct", result.To))
+	}
+	if opts.PreserveComments {
+		var node yaml.Node
+		if err := unmarshalYAML(content, &node); err != nil {
+			return UpgradeResult{}, wrapErr(err)
+		}
+		result.Content, err = s.marshalMerged(&node, reflect.Indirect(reflect.ValueOf(decoded)).Type(), config)
+	} else {
+		result.Content, err = s.marshal(config)
+	}
+	if err != nil {
+		return UpgradeResult{}, wrapErr(err)
+	}
+	result.Upgraded = true
+	if opts.DryRun {
+		return result, nil
+	}
+
+	if err := s.writeFile(path, result.Content, opts.WriteOptions); err != nil {
+		return UpgradeResult{}, wrapErr(err)
+	}
+	s.logger.Info("config upgraded", "path", path, "from", result.From, "to", result.To)
+	return result, nil
+}
@@ -0,0 +1,56 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	versions "github.com/davide-camponogara/versioningyaml/versions_test"
+)
+
+func TestUpgradeFile(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	old, err := os.ReadFile("testdata/configv1.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, old, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dry, err := r.UpgradeFile(path, UpgradeOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dry.Upgraded || dry.From != 1 || dry.To != 3 || len(dry.Content) == 0 {
+		t.Fatalf("unexpected dry run result %+v", dry)
+	}
+	if content, _ := os.ReadFile(path); !bytes.Equal(content, old) {
+		t.Fatal("dry run must not write the file")
+	}
+
+	result, err := r.UpgradeFile(path, UpgradeOptions{WriteOptions: WriteOptions{Backup: Backup}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(path)
+	if !bytes.Equal(content, dry.Content) || !result.Upgraded {
+		t.Fatalf("unexpected upgraded file:\n%s", content)
+	}
+	if backup, _ := os.ReadFile(path + ".bak"); !bytes.Equal(backup, old) {
+		t.Fatal("expected backup of the old file")
+	}
+
+	// already at the newest version
+	result, err = r.UpgradeFile(path, UpgradeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Upgraded || result.From != 3 || result.Content != nil {
+		t.Fatalf("expected no-op, got %+v", result)
+	}
+}
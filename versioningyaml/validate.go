@@ -0,0 +1,466 @@
+package versioningyaml
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+)
+
+// ValidationError collects all the problems found validating a list of versions
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid config versions:\n  - %s", strings.Join(e.Problems, "\n  - "))
+}
+
+// ValidateVersions checks the registered versions of the default registry, see Registry.Validate.
+// Config files are checked by ValidateFile
+func ValidateVersions() error {
+	return defaultRegistry.Validate()
+}
+
+// Validate checks the registered versions (not a config file, see Registry.ValidateFile) and returns
+// a *ValidationError listing all the problems found:
+//   - every Config must be a struct (or nil with Version set for retired versions)
+//     and versions must be unique and in increasing order
+//   - retired versions can only be migrated with node migrations
+//   - the first version can't have Up or Down migrations or hooks since they are never applied
+//   - hooks need the Config structs of both versions and can't be used with node migrations
+//   - a key can't be set in more than one of the custom migrations of a direction (Up, UpE, UpContext)
+//   - every CustomMigration key must be the dotted path of a non struct field of the
+//     destination struct (the version itself for Up, the version before for Down)
+//   - the paths of the Ops must exist in the two versions with convertible types
+//   - a field present in both versions with a different type and without a CustomMigration
+//     or an operation must be convertible from the old type to the new one
+func (r *Registry) Validate() error {
+	return validateVersions(r.snapshot().configVersions)
+}
+
+// SetConfigVersionsStrict validates [cv] and sets it as ConfigVersions of the default registry,
+// see Registry.SetConfigVersionsStrict
+func SetConfigVersionsStrict(cv []utils.ConfigVersion) error {
+	return defaultRegistry.SetConfigVersionsStrict(cv)
+}
+
+// SetConfigVersionsStrict is like SetConfigVersions but validates [cv] first as Validate does,
+// if [cv] is not valid the registry is not changed and a *ValidationError is returned
+func (r *Registry) SetConfigVersionsStrict(cv []utils.ConfigVersion) error {
+	if err := validateVersions(cv); err != nil {
+		return err
+	}
+	r.SetConfigVersions(cv)
+	return nil
+}
+
+// validateVersions checks the list of versions [cv] returning all the problems at once
+func validateVersions(cv []utils.ConfigVersion) error {
+	var problems []string
+	report := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// types contains the struct type of every valid entry, nil for the invalid and retired ones
+	types := make([]reflect.Type, len(cv))
+	seen := map[int]int{}
+	last, lastIndex := 0, -1
+	for i, v := range cv {
+		version := v.Version
+		if v.Config == nil {
+			if v.Version == 0 {
+				report("index %d: Config is nil and Version of the retired version is not set", i)
+				continue
+			}
+			if len(v.UpMigrations()) > 0 || len(v.DownMigrations()) > 0 {
+				report("index %d: retired version %d can't have custom migrations, use node migrations", i, version)
+			}
+		} else {
+			t := reflect.TypeOf(v.Config)
+			if t.Kind() != reflect.Struct {
+				report("index %d: Config %v is not a struct", i, t)
+				continue
+			}
+			types[i] = t
+			if v.Version != 0 && v.Version != v.Config.V() {
+				report("index %d: Version %d differs from the version %d of Config", i, v.Version, v.Config.V())
+			}
+			version = v.Config.V()
+		}
+
+		if j, ok := seen[version]; ok {
+			report("index %d: version %d is duplicated (already at index %d)", i, version, j)
+		} else if lastIndex >= 0 && version < last {
+			report("index %d: version %d is lower than version %d at index %d", i, version, last, lastIndex)
+		}
+		seen[version] = i
+		last, lastIndex = version, i
+	}
+
+	if len(cv) > 0 {
+		if len(cv[0].UpMigrations()) > 0 || cv[0].NodeUp != nil {
+			report("index 0: the first version has Up migrations that are never applied")
+		}
+		if len(cv[0].DownMigrations()) > 0 || cv[0].NodeDown != nil {
+			report("index 0: the first version has Down migrations that are never applied")
+		}
+		if cv[0].BeforeUp != nil || cv[0].AfterUp != nil || cv[0].BeforeDown != nil || cv[0].AfterDown != nil {
+			report("index 0: the first version has hooks that are never called")
+		}
+	}
+
+	for i := 1; i < len(cv); i++ {
+		for _, p := range checkSharedKeys("Up", cv[i].Up, cv[i].UpE, cv[i].UpContext) {
+			report("index %d: %s", i, p)
+		}
+		for _, p := range checkSharedKeys("Down", cv[i].Down, cv[i].DownE, cv[i].DownContext) {
+			report("index %d: %s", i, p)
+		}
+		if cv[i].NodeUp != nil && len(cv[i].UpMigrations()) > 0 {
+			report("index %d: both Up and NodeUp are set, NodeUp replaces Up", i)
+		}
+		if cv[i].NodeDown != nil && len(cv[i].DownMigrations()) > 0 {
+			report("index %d: both Down and NodeDown are set, NodeDown replaces Down", i)
+		}
+		if cv[i-1].Config == nil && cv[i].NodeUp == nil && len(cv[i].UpMigrations()) > 0 {
+			report("index %d: Up custom migrations need the Config struct of the version before, that is retired, use NodeUp", i)
+		}
+		if cv[i-1].Config == nil && cv[i].NodeDown == nil {
+			report("index %d: NodeDown is needed to migrate down to the retired version at index %d", i, i-1)
+		}
+		upHooks := cv[i].BeforeUp != nil || cv[i].AfterUp != nil
+		downHooks := cv[i].BeforeDown != nil || cv[i].AfterDown != nil
+		if (upHooks || downHooks) && (cv[i-1].Config == nil || cv[i].Config == nil) {
+			report("index %d: hooks need the Config structs of both versions", i)
+		}
+		if upHooks && cv[i].NodeUp != nil {
+			report("index %d: Up hooks can't be used with NodeUp", i)
+		}
+		if downHooks && cv[i].NodeDown != nil {
+			report("index %d: Down hooks can't be used with NodeDown", i)
+		}
+
+		prev, cur := types[i-1], types[i]
+		if prev == nil || cur == nil {
+			continue
+		}
+		if cv[i].NodeUp == nil {
+			step := fmt.Sprintf("index %d (%v -> %v)", i, prev, cur)
+			for _, p := range checkMigrationKeys(cur, cv[i].UpMigrations()) {
+				report("%s: Up %s", step, p)
+			}
+			for _, p := range checkOperations(prev, cur, cv[i].Ops) {
+				report("%s: Up %s", step, p)
+			}
+			for _, p := range checkRounding(cur, cv[i].Ops) {
+				report("%s: Up %s", step, p)
+			}
+			for _, p := range checkConversions(prev, cur, "", handledPaths(cv[i].UpMigrations(), cv[i].Ops), typePairs{}) {
+				report("%s: Up %s", step, p)
+			}
+		} else if len(cv[i].Ops) > 0 {
+			report("index %d: both Ops and NodeUp are set, NodeUp replaces Ops", i)
+		}
+		if cv[i].NodeDown == nil {
+			step := fmt.Sprintf("index %d (%v -> %v)", i, cur, prev)
+			inverse := downOperations(cv[i].Ops, prev)
+			for _, p := range checkMigrationKeys(prev, cv[i].DownMigrations()) {
+				report("%s: Down %s", step, p)
+			}
+			for _, p := range checkOperations(cur, prev, inverse) {
+				report("%s: Down %s", step, p)
+			}
+			for _, p := range checkConversions(cur, prev, "", handledPaths(cv[i].DownMigrations(), inverse), typePairs{}) {
+				report("%s: Down %s", step, p)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// checkMigrationKeys checks that every key of [migration] is the path of a non struct field of [t]
+func checkMigrationKeys(t reflect.Type, migration utils.CustomMigrationContext) []string {
+	keys := keysOf(migration)
+	sort.Strings(keys)
+
+	var problems []string
+	for _, key := range keys {
+		field, err := fieldByPath(t, key)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("migration %q: %v", key, err))
+		} else if field.Type.Kind() == reflect.Struct {
+			problems = append(problems, fmt.Sprintf("migration %q: field is a struct, custom migrations apply only to non struct fields", key))
+		}
+	}
+	return problems
+}
+
+// checkSharedKeys checks that no key is set in more than one of the custom migrations of [direction]:
+// [m] (Up or Down), [e] (UpE or DownE) and [c] (UpContext or DownContext)
+func checkSharedKeys(direction string, m utils.CustomMigration, e utils.CustomMigrationE, c utils.CustomMigrationContext) []string {
+	names := []string{direction, direction + "E", direction + "Context"}
+	keys := [][]string{keysOf(m), keysOf(e), keysOf(c)}
+	var problems []string
+	for a := 0; a < len(keys); a++ {
+		for b := a + 1; b < len(keys); b++ {
+			for _, key := range sharedKeys(keys[a], keys[b]) {
+				problems = append(problems, fmt.Sprintf("migration %q is set in both %s and %s", key, names[a], names[b]))
+			}
+		}
+	}
+	return problems
+}
+
+// keysOf returns the keys of the map [m]
+func keysOf[M ~map[string]V, V any](m M) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// sharedKeys returns the sorted keys present in both [a] and [b]
+func sharedKeys(a, b []string) []string {
+	inA := map[string]bool{}
+	for _, key := range a {
+		inA[key] = true
+	}
+	var shared []string
+	for _, key := range b {
+		if inA[key] {
+			shared = append(shared, key)
+		}
+	}
+	sort.Strings(shared)
+	return shared
+}
+
+// fieldByPath returns the field of struct type [t] addressed by the dotted [path].
+// A segment ending with "[]" addresses the elements of a slice, array or map field,
+// pointers are followed
+func fieldByPath(t reflect.Type, path string) (reflect.StructField, error) {
+	var field reflect.StructField
+	segments := strings.Split(path, ".")
+	for i, segment := range segments {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return field, fmt.Errorf("%s is not a struct", strings.Join(segments[:i], "."))
+		}
+		name := strings.TrimRight(segment, "[]")
+		f, ok := t.FieldByName(name)
+		if !ok {
+			return field, fmt.Errorf("field %s not found in %v", name, t)
+		}
+		field, t = f, f.Type
+		for elems := strings.Count(segment[len(name):], "[]"); elems > 0; elems-- {
+			switch t.Kind() {
+			case reflect.Slice, reflect.Array, reflect.Map:
+				t = t.Elem()
+			default:
+				return field, fmt.Errorf("%s is not a slice, array or map", strings.Join(append(segments[:i:i], name), "."))
+			}
+			field.Type = t
+		}
+	}
+	return field, nil
+}
+
+// checkOperations checks that the paths of [ops] exist in [sourceType] and [destType]
+// and that the values they move can be converted. Paths follow pointers as the operations do,
+// the elements of slices and maps ([] segments) can't be addressed
+func checkOperations(sourceType, destType reflect.Type, ops []utils.Operation) []string {
+	var problems []string
+	report := func(op utils.Operation, format string, args ...any) {
+		problems = append(problems, fmt.Sprintf("operation %v: %s", op, fmt.Sprintf(format, args...)))
+	}
+	for _, op := range ops {
+		if strings.Contains(op.From, "[]") || strings.Contains(op.To, "[]") {
+			report(op, "operations can't address the elements of slices and maps")
+			continue
+		}
+		switch op.Kind {
+		case utils.RenameOp, utils.MoveOp:
+			from, err := fieldByPath(sourceType, op.From)
+			if err != nil {
+				report(op, "%v", err)
+				continue
+			}
+			to, err := fieldByPath(destType, op.To)
+			if err != nil {
+				report(op, "%v", err)
+				continue
+			}
+			if !convertible(from.Type, to.Type) {
+				report(op, "%v is not convertible to %v", from.Type, to.Type)
+			}
+		case utils.DropOp:
+			if _, err := fieldByPath(sourceType, op.From); err != nil {
+				report(op, "%v", err)
+			}
+		case utils.SetDefaultOp:
+			to, err := fieldByPath(destType, op.To)
+			if err != nil {
+				report(op, "%v", err)
+			} else if op.Value != nil && !convertible(reflect.TypeOf(op.Value), to.Type) {
+				report(op, "%T is not convertible to %v", op.Value, to.Type)
+			}
+		case utils.ScaleOp, utils.WrapOp, utils.UnwrapOp:
+			from, err := fieldByPath(sourceType, op.From)
+			if err != nil {
+				report(op, "%v", err)
+				continue
+			}
+			to, err := fieldByPath(destType, op.To)
+			if err != nil {
+				report(op, "%v", err)
+				continue
+			}
+			if p := checkShapeOperation(op, from.Type, to.Type); p != "" {
+				report(op, "%s", p)
+			}
+		default:
+			report(op, "unknown operation kind")
+		}
+	}
+	return problems
+}
+
+// checkRounding checks that the Scale operations of [ops] setting an integer field of [destType]
+// have an integer factor: migrating up would round values that migrating down can't restore
+func checkRounding(destType reflect.Type, ops []utils.Operation) []string {
+	var problems []string
+	for _, op := range ops {
+		factor, ok := op.Value.(float64)
+		if op.Kind != utils.ScaleOp || !ok {
+			continue
+		}
+		to, err := fieldByPath(destType, op.To)
+		if err == nil && isInteger(to.Type) && factor != math.Trunc(factor) {
+			problems = append(problems, fmt.Sprintf("operation %v: factor %v rounds the integer field %s", op, factor, op.To))
+		}
+	}
+	return problems
+}
+
+// checkShapeOperation checks the types [from] and [to] of the fields of the operation [op]
+// that changes the shape of a value: Scale, Wrap and Unwrap
+func checkShapeOperation(op utils.Operation, from, to reflect.Type) string {
+	switch op.Kind {
+	case utils.ScaleOp:
+		if factor, ok := op.Value.(float64); !ok || factor == 0 {
+			return fmt.Sprintf("factor %v is not a non zero float64", op.Value)
+		}
+		if !isNumber(from) || !isNumber(to) {
+			return fmt.Sprintf("%v and %v must be numbers", from, to)
+		}
+	case utils.WrapOp:
+		if to.Kind() != reflect.Slice {
+			return fmt.Sprintf("%v is not a slice", to)
+		}
+		if !convertible(from, to.Elem()) {
+			return fmt.Sprintf("%v is not convertible to %v", from, to.Elem())
+		}
+	case utils.UnwrapOp:
+		if from.Kind() != reflect.Slice && from.Kind() != reflect.Array {
+			return fmt.Sprintf("%v is not a slice", from)
+		}
+		if !convertible(from.Elem(), to) {
+			return fmt.Sprintf("%v is not convertible to %v", from.Elem(), to)
+		}
+	}
+	return ""
+}
+
+// isNumber tells if [t] is an integer or floating point type
+func isNumber(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// handledPaths returns the paths migrated by a custom [migration] or an operation of [ops]
+func handledPaths(migration utils.CustomMigrationContext, ops []utils.Operation) map[string]bool {
+	handled := map[string]bool{}
+	for key := range migration {
+		handled[key] = true
+	}
+	for _, op := range ops {
+		handled[op.Path()] = true
+	}
+	return handled
+}
+
+// typePairs is a set of source and destination types
+type typePairs map[[2]reflect.Type]bool
+
+// checkConversions walks the fields of [destType] as MigrateOne does and checks that every field
+// found also in [sourceType] can be converted, unless it is [handled] by a custom migration or an operation.
+// [checking] are the types being checked up the recursion, so that recursive types are walked once
+func checkConversions(sourceType, destType reflect.Type, prefix string, handled map[string]bool, checking typePairs) []string {
+	var problems []string
+	for i := 0; i < destType.NumField(); i++ {
+		destField := destType.Field(i)
+		path := prefix + destField.Name
+		if destField.Name == "Version" {
+			continue
+		}
+		sourceField, ok := sourceType.FieldByName(destField.Name)
+		if !ok {
+			continue
+		}
+		problems = append(problems, checkConversion(sourceField.Type, destField.Type, path, handled, checking)...)
+	}
+	return problems
+}
+
+// checkConversion checks that a value of [sourceType] can be migrated to [destType] as migrateValue does,
+// walking the elements of slices, arrays, maps and pointers with path [path]+"[]"
+func checkConversion(sourceType, destType reflect.Type, path string, handled map[string]bool, checking typePairs) []string {
+	pair := [2]reflect.Type{sourceType, destType}
+	if handled[path] || checking[pair] {
+		return nil
+	}
+	checking[pair] = true
+	defer delete(checking, pair)
+
+	switch {
+	case destType.Kind() == reflect.Ptr && (sourceType.Kind() == reflect.Ptr || sourceType.Kind() == destType.Elem().Kind()):
+		if sourceType.Kind() == reflect.Ptr {
+			sourceType = sourceType.Elem()
+		}
+		return checkConversion(sourceType, destType.Elem(), path, handled, checking)
+	case destType.Kind() == reflect.Struct && sourceType.Kind() == reflect.Ptr:
+		return checkConversion(sourceType.Elem(), destType, path, handled, checking)
+	case destType.Kind() == reflect.Struct && sourceType.Kind() == reflect.Struct:
+		return checkConversions(sourceType, destType, path+".", handled, checking)
+	case (destType.Kind() == reflect.Slice || destType.Kind() == reflect.Array) &&
+		(sourceType.Kind() == reflect.Slice || sourceType.Kind() == reflect.Array):
+		if destType.Kind() == reflect.Array && sourceType.Kind() == reflect.Array && sourceType.Len() > destType.Len() {
+			return []string{fmt.Sprintf("field %s: %v doesn't fit in %v and has no custom migration", path, sourceType, destType)}
+		}
+		return checkConversion(sourceType.Elem(), destType.Elem(), path+"[]", handled, checking)
+	case destType.Kind() == reflect.Map && sourceType.Kind() == reflect.Map:
+		if convertible(sourceType.Key(), destType.Key()) {
+			return checkConversion(sourceType.Elem(), destType.Elem(), path+"[]", handled, checking)
+		}
+	}
+	if !convertible(sourceType, destType) {
+		return []string{fmt.Sprintf("field %s: %v is not convertible to %v and has no custom migration", path, sourceType, destType)}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/davide-camponogara/versioningyaml/utils"
+	versions "github.com/davide-camponogara/versioningyaml/versions_test"
+)
+
+type badV1 struct {
+	Version int
+	Street  versions.Street
+	Test    map[int]bool
+}
+
+func (badV1) V() int { return 1 }
+
+type badV2 struct {
+	Version int
+	Street  versions.Street
+	Test    map[string]bool
+}
+
+func (badV2) V() int { return 2 }
+
+func TestValidateExampleVersions(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+	if err := r.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	noop := func(c any) any { return nil }
+	cv := []utils.ConfigVersion{
+		{Config: badV1{}, Down: utils.CustomMigration{"Test": noop}},
+		{Config: badV2{}, Up: utils.CustomMigration{"Street.Label": noop, "Street": noop}},
+		{Config: badV2{}},
+	}
+	r := NewRegistry(nil)
+	err := r.SetConfigVersionsStrict(cv)
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	expected := []string{
+		"index 0: the first version has Down migrations",
+		"index 1 (versioningyaml.badV1 -> versioningyaml.badV2): Up migration \"Street.Label\": field Label not found",
+		"index 1 (versioningyaml.badV1 -> versioningyaml.badV2): Up migration \"Street\": field is a struct",
+		"index 1 (versioningyaml.badV1 -> versioningyaml.badV2): Up field Test: map[int]bool is not convertible to map[string]bool",
+		"index 1 (versioningyaml.badV2 -> versioningyaml.badV1): Down field Test: map[string]bool is not convertible to map[int]bool",
+		"index 2: version 2 is duplicated (already at index 1)",
+	}
+	for _, e := range expected {
+		found := false
+		for _, p := range verr.Problems {
+			if strings.HasPrefix(p, e) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("problem %q not reported in:\n%v", e, verr)
+		}
+	}
+	if r.snapshot().configVersions != nil {
+		t.Fatal("invalid versions must not be set")
+	}
+
+	err = validateVersions([]utils.ConfigVersion{{Config: badV2{}}, {Config: badV1{}}})
+	if err == nil || !strings.Contains(err.Error(), "index 1: version 1 is lower than version 2 at index 0") {
+		t.Fatalf("expected ordering problem, got %v", err)
+	}
+}
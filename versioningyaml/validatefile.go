@@ -0,0 +1,225 @@
+package versioningyaml
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IssueKind is the kind of an Issue found validating a yaml file
+type IssueKind int
+
+const (
+	// UnknownKey is a key that is not a field of the Config struct
+	UnknownKey IssueKind = iota
+	// MissingField is a required field missing in the file, see JSONSchema for the required fields
+	MissingField
+	// TypeMismatch is a value that can't be decoded in the type of its field
+	TypeMismatch
+)
+
+func (k IssueKind) String() string {
+	switch k {
+	case UnknownKey:
+		return "unknown key"
+	case MissingField:
+		return "missing field"
+	case TypeMismatch:
+		return "type mismatch"
+	}
+	return fmt.Sprintf("IssueKind(%d)", int(k))
+}
+
+// Issue is a problem found validating a yaml file, at Line and Column of the file
+type Issue struct {
+	Kind         IssueKind
+	Line, Column int
+	// Path is the dotted path of yaml keys of the value, the parent mapping for MissingField
+	Path    string
+	Message string
+}
+
+func (i Issue) String() string {
+	path := i.Path
+	if path == "" {
+		path = "<root>"
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", i.Line, i.Column, path, i.Message)
+}
+
+// ValidateFile checks the yaml file in [path] against the Config struct of its version
+// using the default registry, see Registry.ValidateFile
+func ValidateFile(path string) ([]Issue, error) {
+	return defaultRegistry.ValidateFile(path)
+}
+
+// ValidateFile detects the version of the yaml file in [path] and checks it against the Config struct
+// of that version (the registered versions are checked by Registry.Validate), returning all the issues
+// found: keys that are not fields (yaml.Unmarshal silently ignores them), required fields that are
+// missing and values of the wrong type.
+// The error is returned only when the file can't be read or parsed or its version is unknown
+func (r *Registry) ValidateFile(path string) ([]Issue, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("validating %s: %w", path, err)
+	}
+	s := r.snapshot()
+	content, err := s.readFile(path)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	issues, err := s.validateContent(content)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return issues, nil
+}
+
+// validateContent checks the yaml [content] against the Config struct of its version
+func (s *snapshot) validateContent(content []byte) ([]Issue, error) {
+	version, err := s.getVersion(content)
+	if err != nil {
+		return nil, err
+	}
+	cv, i := s.findByVersion(version)
+	if i < 0 {
+		return nil, fmt.Errorf("version %d not registered", version)
+	}
+	if cv.Config == nil {
+		return nil, fmt.Errorf("version %d is retired and has no Config struct", version)
+	}
+
+	var doc yaml.Node
+	if err := unmarshalYAML(content, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, fmt.Errorf("empty document")
+	}
+	return checkNode(doc.Content[0], reflect.TypeOf(cv.Config), ""), nil
+}
+
+// checkNode checks that the yaml [node] with path [path] can be decoded in the type [t]
+func checkNode(node *yaml.Node, t reflect.Type, path string) []Issue {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	// null is decoded as the zero value, types with a Config() method can have any format
+	if (node.Kind == yaml.ScalarNode && node.Tag == "!!null") ||
+		t.Implements(configFormatter) || reflect.PtrTo(t).Implements(configFormatter) {
+		return nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	mismatch := func(expected string) []Issue {
+		return []Issue{{Kind: TypeMismatch, Line: node.Line, Column: node.Column, Path: path,
+			Message: fmt.Sprintf("expected %s of type %v, found %s", expected, t, nodeKind(node))}}
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return nil
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return mismatch("a mapping")
+		}
+		return checkMapping(node, t, path)
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return mismatch("a mapping")
+		}
+		var issues []Issue
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := joinPath(path, key.Value)
+			if err := key.Decode(reflect.New(t.Key()).Interface()); err != nil {
+				issues = append(issues, Issue{Kind: TypeMismatch, Line: key.Line, Column: key.Column, Path: keyPath,
+					Message: fmt.Sprintf("key %q is not of type %v", key.Value, t.Key())})
+			}
+			issues = append(issues, checkNode(value, t.Elem(), keyPath)...)
+		}
+		return issues
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			return mismatch("a sequence")
+		}
+		var issues []Issue
+		if t.Kind() == reflect.Array && len(node.Content) > t.Len() {
+			issues = append(issues, Issue{Kind: TypeMismatch, Line: node.Line, Column: node.Column, Path: path,
+				Message: fmt.Sprintf("%d elements, at most %d allowed", len(node.Content), t.Len())})
+		}
+		for i, item := range node.Content {
+			issues = append(issues, checkNode(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return issues
+	}
+
+	if node.Kind != yaml.ScalarNode {
+		return mismatch("a scalar")
+	}
+	if err := node.Decode(reflect.New(t).Interface()); err != nil {
+		return []Issue{{Kind: TypeMismatch, Line: node.Line, Column: node.Column, Path: path,
+			Message: fmt.Sprintf("%q is not of type %v", node.Value, t)}}
+	}
+	return nil
+}
+
+// checkMapping checks the keys of the mapping [node] against the fields of the struct type [t]
+func checkMapping(node *yaml.Node, t reflect.Type, path string) []Issue {
+	fields := map[string]reflect.StructField{}
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := yamlKey(field)
+		if field.PkgPath != "" || key == "-" {
+			continue
+		}
+		fields[key] = field
+		keys = append(keys, key)
+	}
+
+	var issues []Issue
+	present := map[string]bool{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		keyPath := joinPath(path, key.Value)
+		field, ok := fields[key.Value]
+		if !ok {
+			issues = append(issues, Issue{Kind: UnknownKey, Line: key.Line, Column: key.Column, Path: keyPath,
+				Message: fmt.Sprintf("unknown key %q", key.Value)})
+			continue
+		}
+		present[key.Value] = true
+		issues = append(issues, checkNode(value, field.Type, keyPath)...)
+	}
+	for _, key := range keys {
+		if !present[key] && isRequired(fields[key]) {
+			issues = append(issues, Issue{Kind: MissingField, Line: node.Line, Column: node.Column, Path: path,
+				Message: fmt.Sprintf("missing required field %q", key)})
+		}
+	}
+	return issues
+}
+
+// joinPath appends [key] to the dotted [path]
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// nodeKind describes the kind of [node] for the messages
+func nodeKind(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a sequence"
+	case yaml.ScalarNode:
+		return fmt.Sprintf("%q", node.Value)
+	}
+	return strings.TrimPrefix(node.ShortTag(), "!!")
+}
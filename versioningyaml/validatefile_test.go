@@ -0,0 +1,75 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	versions "github.com/davide-camponogara/versioningyaml/versions_test"
+)
+
+func TestValidateFile(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+
+	issues, err := r.ValidateFile("testdata/configv2.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 0 {
+		t.Fatalf("expected no issues, got %v", issues)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "version: 2\nstreet:\n  Feild1: 12\n  Name: Main Street\ncity: [Rome]\ntest: {one: true}\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	issues, err = r.ValidateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Issue{
+		{Kind: UnknownKey, Line: 3, Column: 3, Path: "street.Feild1"},
+		{Kind: MissingField, Line: 3, Column: 3, Path: "street"},
+		{Kind: TypeMismatch, Line: 5, Column: 7, Path: "city"},
+		{Kind: TypeMismatch, Line: 6, Column: 8, Path: "test.one"},
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("expected %d issues, got %v", len(expected), issues)
+	}
+	for i, issue := range issues {
+		issue.Message = ""
+		if issue != expected[i] {
+			t.Errorf("issue %d: expected %+v, got %+v", i, expected[i], issue)
+		}
+	}
+	if s := issues[0].String(); s != `3:3: street.Feild1: unknown key "Feild1"` {
+		t.Errorf("unexpected issue string %q", s)
+	}
+}
+
+func TestValidateFileUnknownVersion(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("version: 42\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ValidateFile(path); err == nil {
+		t.Fatal("expected an error for an unregistered version")
+	}
+}
+
+func TestValidateFileDefaultRegistry(t *testing.T) {
+	SetConfigVersions(versions.ConfigVersions)
+
+	issues, err := ValidateFile("testdata/configv1.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 0 {
+		t.Fatalf("expected no issues, got %v", issues)
+	}
+}
@@ -4,6 +4,7 @@ package versioningyaml
 
 import (
 	"fmt"
+	"path/filepath"
 	"testing"
 
 	versions "github.com/davide-camponogara/versioningyaml/versions_test"
@@ -13,7 +14,7 @@ func TestMigration(t *testing.T) {
 	SetConfigVersions(versions.ConfigVersions)
 	SetLongComments(versions.LongComments)
 
-	config, version, err := LoadConfigVersioned("../configv2.yaml")
+	config, version, err := LoadConfigVersioned("testdata/configv2.yaml")
 	if err != nil {
 		panic(err)
 	}
@@ -26,5 +27,98 @@ func TestMigration(t *testing.T) {
 	configv2 := c.(*versions.ConfigV3)
 
 	fmt.Printf("%#v", configv2)
-	WriteYaml(*configv2, "../config_test_v2.yaml")
+	if err := WriteYaml(*configv2, filepath.Join(t.TempDir(), "config_test_v2.yaml")); err != nil {
+		panic(err)
+	}
+}
+
+func TestRegistriesAreIndependent(t *testing.T) {
+	full := NewRegistry(versions.ConfigVersions)
+	full.SetLongComments(versions.LongComments)
+	partial := NewRegistry(versions.ConfigVersions[:2])
+	partial.SetDefaultVersion(2)
+
+	config, version, err := full.LoadConfigVersioned("testdata/configv1.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 {
+		t.Fatalf("expected version 1, got %d", version)
+	}
+	if _, err := full.MigrateUp(config, &versions.ConfigV3{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the same file is read as version 2 by the registry with a different default version
+	_, version, err = partial.LoadConfigVersioned("testdata/configv1.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 {
+		t.Fatalf("expected version 2, got %d", version)
+	}
+	if _, err := partial.MigrateUp(config, &versions.ConfigV3{}); err == nil {
+		t.Fatal("expected error migrating to a version not registered")
+	}
+}
+
+func TestMigrateTo(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+	config, _, err := r.LoadConfigVersioned("testdata/configv2.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	up, err := r.MigrateTo(config, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v3, ok := up.(*versions.ConfigV3)
+	if !ok || v3.Street.Name != "12 Main Street" {
+		t.Fatalf("unexpected migration up %#v", up)
+	}
+
+	down, err := r.MigrateTo(v3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v1, ok := down.(*versions.ConfigV1); !ok || v1.Version != 1 || v1.City != "12 Main Street" {
+		t.Fatalf("unexpected migration down %#v", down)
+	}
+
+	same, err := r.MigrateTo(config, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2, ok := same.(*versions.ConfigV2); !ok || v2.City != "Rome" {
+		t.Fatalf("unexpected copy %#v", same)
+	}
+
+	if _, err := r.MigrateTo(config, 4); err == nil {
+		t.Fatal("expected error migrating to an unknown version")
+	}
+}
+
+func TestMigrateNilSource(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+	var nilV1 *versions.ConfigV1
+
+	if _, err := r.MigrateTo(nilV1, 3); err == nil {
+		t.Fatal("expected error migrating a nil pointer")
+	}
+	if _, err := r.MigrateTo(nil, 3); err == nil {
+		t.Fatal("expected error migrating nil")
+	}
+	if _, err := r.MigrateUp(nilV1, &versions.ConfigV3{}); err == nil {
+		t.Fatal("expected error migrating up a nil pointer")
+	}
+	if _, err := r.MigrateUp(versions.ConfigV1{Version: 1}, (*versions.ConfigV3)(nil)); err == nil {
+		t.Fatal("expected error migrating up to a nil pointer")
+	}
+	if _, err := r.MigrateDown((*versions.ConfigV3)(nil), &versions.ConfigV1{}); err == nil {
+		t.Fatal("expected error migrating down a nil pointer")
+	}
+	if _, err := MigrateUpToWith[*versions.ConfigV3](r, nilV1); err == nil {
+		t.Fatal("expected error migrating up to T a nil pointer")
+	}
 }
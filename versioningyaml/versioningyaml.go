@@ -2,11 +2,11 @@ package versioningyaml
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"os"
 	"reflect"
 	"regexp"
 	"strings"
@@ -16,79 +16,68 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-// ConfigVersions contains an ordered list of the versions of the yaml file
-// every entry is a ConfigVersion object that contains:
-//   - the Config struct
-//   - the CustomMigration UP
-//   - the CustomMigration DOWN
-//
-// the custom migrations are unes when one have to manipulate the fileds instead
-// if a field doesn't change from the version before or is one is a new (standalone) field a CustomMigration is not needed
-var configVersions []utils.ConfigVersion
-
-// SetConfigVersions setter for ConfigVersions
-//
-// ConfigVersions contains an ordered list of the versions of the yaml file
-// every entry is a ConfigVersion object that contains:
-//   - the Config struct
-//   - the CustomMigration UP
-//   - the CustomMigration DOWN
-//
-// the custom migrations are unes when one have to manipulate the fileds instead
-// if a field doesn't change from the version before or is one is a new (standalone) field a CustomMigration is not needed
-func SetConfigVersions(cv []utils.ConfigVersion) {
-	configVersions = cv
+// WriteYaml create a yaml file with name [name] from the tagged [data] struct
+// using the default registry
+func WriteYaml(data utils.Config, path string) error {
+	return defaultRegistry.WriteYaml(data, path)
 }
 
-// defaultVersion contains the default version of a .yaml where the field "version" is not present
-var defaultVersion int = 1
-
-// SetDefaultVersion setter for defaultVersion
-//
-// defaultVersion contains the default version of a .yaml where the field "version" is not present
-func SetDefaultVersion(defVersion int) {
-	defaultVersion = defVersion
+// WriteYaml create a yaml file with name [name] from the tagged [data] struct (or pointer to it).
+// The file is replaced atomically and keeps its mode if it already exists, see WriteYamlWithOptions
+func (r *Registry) WriteYaml(data utils.Config, path string) error {
+	return r.snapshot().writeYaml(data, path, WriteOptions{})
 }
 
-// LongComments is a map containing long comments
-// by convenction a reference to a long comment is denoted with a $ in form of the name
-var longComments map[string]string
-
-// indent is the indendtation spaces
-var indent int = 3
-
-// SetIndent setter for indent (default 3)
-func SetIndent(indentation int) {
-	indent = indentation
+// WriteYamlWithOptions is like WriteYaml using the default registry and the options [opts]
+func WriteYamlWithOptions(data utils.Config, path string, opts WriteOptions) error {
+	return defaultRegistry.WriteYamlWithOptions(data, path, opts)
 }
 
-// SetLongComments setter for LongComments
-//
-// LongComments is a map containing long comments
-// by convenction a reference to a long comment is denoted with a $ in form of the name
-func SetLongComments(lc map[string]string) {
-	longComments = lc
+// WriteYamlWithOptions is like WriteYaml with the options [opts] for syncing and backups
+func (r *Registry) WriteYamlWithOptions(data utils.Config, path string, opts WriteOptions) error {
+	return r.snapshot().writeYaml(data, path, opts)
 }
 
-// WriteYaml create a yaml file with name [name] from the tagged [data] struct
-func WriteYaml(data utils.Config, path string) error {
+// writeYaml create a yaml file with name [name] from the tagged [data] struct
+func (s *snapshot) writeYaml(data utils.Config, path string, opts WriteOptions) error {
 	wrapErr := func(err error) error {
 		return fmt.Errorf("writing yaml: %w", err)
 	}
+	yamlBytes, err := s.marshal(data)
+	if err != nil {
+		return wrapErr(err)
+	}
+
+	if err := s.writeFile(path, yamlBytes, opts); err != nil {
+		return wrapErr(err)
+	}
+	s.logger.Info("config written", "path", path, "version", data.V())
+
+	return nil
+}
+
+// marshal renders the tagged [data] struct (or pointer to it) as yaml
+func (s *snapshot) marshal(data utils.Config) ([]byte, error) {
 	// Create a YAML nodes representation of the Address struct
-	yamlObject, err := GenerateYAMLobject(data, 0)
+	// pointers to the Config struct are written as the struct itself
+	yamlObject, err := s.generateYAMLobject(reflect.Indirect(reflect.ValueOf(data)).Interface(), 0)
 	if err != nil {
-		return wrapErr(fmt.Errorf("error generating YAML: %w", err))
+		return nil, fmt.Errorf("error generating YAML: %w", err)
 	}
+	return s.encodeNode(yamlObject)
+}
 
+// encodeNode renders the yaml node [yamlObject] with the indentation of the registry
+// writing the fields tagged as short in json format
+func (s *snapshot) encodeNode(yamlObject *yaml.Node) ([]byte, error) {
 	// Create a buffer to write YAML to
 	var b bytes.Buffer
 	yamlEncoder := yaml.NewEncoder(&b)
-	yamlEncoder.SetIndent(indent)
+	yamlEncoder.SetIndent(s.indent)
 
 	// Encode YAML object to the buffer
 	if err := yamlEncoder.Encode(yamlObject); err != nil {
-		return wrapErr(fmt.Errorf("error encoding YAML: %w", err))
+		return nil, fmt.Errorf("error encoding YAML: %w", err)
 	}
 
 	// Convert buffer to string
@@ -111,20 +100,7 @@ func WriteYaml(data utils.Config, path string) error {
 		}
 	}
 
-	// Write the YAML to a file
-	file, err := os.Create(path)
-	if err != nil {
-		return wrapErr(fmt.Errorf("error creating file: %w", err))
-	}
-	defer file.Close()
-
-	_, err = file.WriteString(yamlString)
-	if err != nil {
-		return wrapErr(fmt.Errorf("error writing YAML to file: %w", err))
-	}
-	fmt.Printf("%v file created successfully.", path)
-
-	return nil
+	return []byte(yamlString), nil
 }
 
 // formatJson fix spaces in json format (needed to yaml unmarshaling) and
@@ -138,9 +114,22 @@ func formatJson(jsonData string, t reflect.Type) string {
 	return modJson
 }
 
-// generateYAMLobject generates Node object formatted for a yaml file
+// GenerateYAMLobject generates Node object formatted for a yaml file
+// using the long comments of the default registry
 // gets level gor styling the black lines in comments
 func GenerateYAMLobject(data interface{}, level int) (*yaml.Node, error) {
+	return defaultRegistry.GenerateYAMLobject(data, level)
+}
+
+// GenerateYAMLobject generates Node object formatted for a yaml file
+// gets level gor styling the black lines in comments
+func (r *Registry) GenerateYAMLobject(data interface{}, level int) (*yaml.Node, error) {
+	return r.snapshot().generateYAMLobject(data, level)
+}
+
+// generateYAMLobject generates Node object formatted for a yaml file
+// gets level gor styling the black lines in comments
+func (s *snapshot) generateYAMLobject(data interface{}, level int) (*yaml.Node, error) {
 	var fieldName string
 	wrapErr := func(err error) error {
 		return fmt.Errorf("generating yaml field %v : %w", fieldName, err)
@@ -160,18 +149,15 @@ func GenerateYAMLobject(data interface{}, level int) (*yaml.Node, error) {
 		lineCommentTag := field.Tag.Get("lineComment") // Get the lineComment tag value
 		_, isJson := field.Tag.Lookup("short")         // Get short flag
 
-		if com, ok := longComments[commentTag]; ok { // Check if comment is key of a long comment and subsitute it
+		if com, ok := s.longComments[commentTag]; ok { // Check if comment is key of a long comment and subsitute it
 			commentTag = com
 		}
 
-		if com, ok := longComments[lineCommentTag]; ok { // Check if lineComment is key of a long comment and subsitute it
+		if com, ok := s.longComments[lineCommentTag]; ok { // Check if lineComment is key of a long comment and subsitute it
 			lineCommentTag = com
 		}
 
-		fieldName = field.Tag.Get("yaml") // Get the yaml tag value
-		if fieldName == "" {
-			fieldName = strings.ToLower(field.Name) // Use the field name as the key if yaml tag is empty
-		}
+		fieldName = yamlKey(field) // Get the yaml key of the field
 
 		// Create key node
 		keyNode := &yaml.Node{
@@ -209,7 +195,7 @@ func GenerateYAMLobject(data interface{}, level int) (*yaml.Node, error) {
 					LineComment: lineCommentTag,
 				}
 			} else {
-				valueNode, err = GenerateYAMLobject(reflect.ValueOf(data).Field(i).Interface(), level+1)
+				valueNode, err = s.generateYAMLobject(reflect.ValueOf(data).Field(i).Interface(), level+1)
 			}
 			// skip line
 			if (i == 0 || reflect.ValueOf(data).Field(i-1).Type().Kind() != reflect.Struct) && level <= 1 {
@@ -286,6 +272,16 @@ func GenerateYAMLobject(data interface{}, level int) (*yaml.Node, error) {
 	return rootNode, nil
 }
 
+// yamlKey returns the key of [field] in the yaml file: the name in the yaml tag
+// or the lowercase field name if the yaml tag is empty
+func yamlKey(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name
+}
+
 // LoadYAML loads a yaml file as object
 func LoadYAML(path string, object interface{}) error {
 	wrapErr := func(err error) error {
@@ -296,10 +292,17 @@ func LoadYAML(path string, object interface{}) error {
 	if err != nil {
 		return wrapErr(fmt.Errorf("error opening file: %w", err))
 	}
-	// Unmarshal the modified YAML string
-	err = yaml.Unmarshal([]byte(bytes), object)
+	if err := unmarshalYAML(bytes, object); err != nil {
+		return wrapErr(err)
+	}
+	return nil
+}
+
+// unmarshalYAML decodes yaml [data] into object
+func unmarshalYAML(data []byte, object interface{}) error {
+	err := yaml.Unmarshal(data, object)
 	if err != nil {
-		return wrapErr(fmt.Errorf("error decoding YAML: %w", err))
+		return fmt.Errorf("error decoding YAML: %w", err)
 	}
 	return nil
 }
@@ -312,13 +315,13 @@ func isValidJSON(s string) bool {
 	return json.Unmarshal([]byte(s), &js) == nil
 }
 
-// getVersion returns version of config file
-func getVersion(path string) (int, error) {
+// getVersion returns version of config yaml [content]
+func (s *snapshot) getVersion(content []byte) (int, error) {
 	wrapErr := func(err error) error {
 		return fmt.Errorf("getting version: %w", err)
 	}
 	var data interface{}
-	err := LoadYAML(path, &data)
+	err := unmarshalYAML(content, &data)
 	if err != nil {
 		return 0, wrapErr(err)
 	}
@@ -332,7 +335,7 @@ func getVersion(path string) (int, error) {
 	// Handle different versions based on the "version" field
 	vField, ok := dataMap["version"]
 	if !ok {
-		return defaultVersion, nil
+		return s.defaultVersion, nil
 	}
 
 	version, ok := vField.(int)
@@ -343,26 +346,100 @@ func getVersion(path string) (int, error) {
 }
 
 // LoadConfigVersioned loads a config file as a struct of the related version and returns also the version
+// using the default registry
 func LoadConfigVersioned(path string) (utils.Config, int, error) {
+	return defaultRegistry.LoadConfigVersioned(path)
+}
+
+// LoadConfigVersioned loads a config file as a struct of the related version and returns also the version
+func (r *Registry) LoadConfigVersioned(path string) (utils.Config, int, error) {
+	return r.snapshot().loadConfigVersioned(context.Background(), path)
+}
+
+// LoadConfigVersionedContext is like LoadConfigVersioned using the default registry,
+// see Registry.LoadConfigVersionedContext
+func LoadConfigVersionedContext(ctx context.Context, path string) (utils.Config, int, error) {
+	return defaultRegistry.LoadConfigVersionedContext(ctx, path)
+}
+
+// LoadConfigVersionedContext is like LoadConfigVersioned, it stops before reading and before decoding
+// the file when [ctx] is done
+func (r *Registry) LoadConfigVersionedContext(ctx context.Context, path string) (utils.Config, int, error) {
+	return r.snapshot().loadConfigVersioned(ctx, path)
+}
+
+// loadConfigVersioned loads a config file as a struct of the related version and returns also the version
+func (s *snapshot) loadConfigVersioned(ctx context.Context, path string) (utils.Config, int, error) {
 	wrapErr := func(err error) error {
 		return fmt.Errorf("loading config versioned: %w", err)
 	}
-	version, err := getVersion(path)
+	if err := ctx.Err(); err != nil {
+		return nil, 0, wrapErr(err)
+	}
+	content, err := s.readFile(path)
 	if err != nil {
 		return nil, 0, wrapErr(err)
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, 0, wrapErr(err)
+	}
+	config, version, err := s.decodeBytes(content)
+	if err != nil {
+		return nil, 0, wrapErr(err)
+	}
+	return config, version, nil
+}
+
+// readFile reads the file in [path]
+func (s *snapshot) readFile(path string) ([]byte, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	s.logger.Debug("config read", "path", path, "bytes", len(content))
+	return content, nil
+}
+
+// decodeBytes decodes yaml [content] as a struct of the related version and returns also the version
+func (s *snapshot) decodeBytes(content []byte) (utils.Config, int, error) {
+	decoded, version, err := s.decode(content)
+	if err != nil {
+		return nil, 0, err
+	}
+	config, ok := decoded.(utils.Config)
+	if !ok {
+		return nil, 0, fmt.Errorf("version %d is retired and has no Config struct, it can only be migrated to a newer version", version)
+	}
+	return config, version, nil
+}
+
+// decode decodes yaml [content] as a struct of the related version, or as yaml tree
+// if the version is retired, and returns also the version
+func (s *snapshot) decode(content []byte) (interface{}, int, error) {
+	version, err := s.getVersion(content)
+	if err != nil {
+		return nil, 0, err
+	}
+	s.logger.Debug("version detected", "version", version)
 
 	// Get the appropriate struct type based on version
-	configType, _ := findByVersion(version)
+	configType, _ := s.findByVersion(version)
 	if configType == nil {
-		return nil, 0, wrapErr(errors.New("error finding version"))
+		return nil, 0, fmt.Errorf("error finding version %d", version)
+	}
+	if configType.Config == nil {
+		var tree map[string]interface{}
+		if err := unmarshalYAML(content, &tree); err != nil {
+			return nil, 0, err
+		}
+		return tree, version, nil
 	}
 
-	// Load YAML into the appropriate struct type
+	// Decode YAML into the appropriate struct type
 	configValue := reflect.New(reflect.TypeOf(configType.Config)).Interface()
-	err = LoadYAML(path, configValue)
+	err = unmarshalYAML(content, configValue)
 	if err != nil {
-		return nil, 0, wrapErr(err)
+		return nil, 0, err
 	}
 	config := reflect.Indirect(reflect.ValueOf(configValue)).Interface()
 
@@ -370,7 +447,19 @@ func LoadConfigVersioned(path string) (utils.Config, int, error) {
 }
 
 // Migrate apply migration from config source to config destination objects
-func MigrateOne(source interface{}, destination interface{}, migration utils.CustomMigration) error {
+// followed by the declarative operations [ops], custom migrations take precedence over operations
+func MigrateOne(source interface{}, destination interface{}, migration utils.CustomMigration, ops ...utils.Operation) error {
+	return MigrateOneE(source, destination, migration.Checked(), ops...)
+}
+
+// MigrateOneE is like MigrateOne with custom migrations that can fail,
+// their errors are returned wrapped with the dotted path of the field
+func MigrateOneE(source interface{}, destination interface{}, migration utils.CustomMigrationE, ops ...utils.Operation) error {
+	return MigrateOneContext(context.Background(), source, destination, migration.WithContext(), ops...)
+}
+
+// MigrateOneContext is like MigrateOneE with custom migrations that receive [ctx]
+func MigrateOneContext(ctx context.Context, source interface{}, destination interface{}, migration utils.CustomMigrationContext, ops ...utils.Operation) error {
 	wrapErr := func(err error) error {
 		return fmt.Errorf("migrating one version: %w", err)
 	}
@@ -378,65 +467,58 @@ func MigrateOne(source interface{}, destination interface{}, migration utils.Cus
 	destValue := reflect.ValueOf(destination).Elem()
 
 	// Get the value of the source struct
-	var sourceValue reflect.Value
-	if reflect.ValueOf(source).Kind() == reflect.Ptr {
-		sourceValue = reflect.ValueOf(source).Elem()
-	} else {
-		sourceValue = reflect.ValueOf(source)
-	}
+	sourceValue := reflect.Indirect(reflect.ValueOf(source))
 
-	for i := 0; i < destValue.NumField(); i++ {
-		field := destValue.Type().Field(i)
-		fieldName := field.Name
-
-		// if field is of struct type
-		if field.Type.Kind() == reflect.Struct {
-			sourceStruct := sourceValue.FieldByName(fieldName)
-			destStruct := destValue.FieldByName(fieldName)
-			err := migrateStruct(sourceStruct, destStruct, fieldName, migration, sourceValue, destination.(utils.Config).V())
-			if err != nil {
-				return wrapErr(err)
-			}
-
-		} else { // if field is not of type struct
-			err := migrateField(sourceValue, destValue, fieldName, migration, sourceValue, destination.(utils.Config).V())
-			if err != nil {
-				return wrapErr(err)
-			}
-		}
+	if err := migrateStruct(ctx, sourceValue, destValue, "", migration, sourceValue, destination.(utils.Config).V()); err != nil {
+		return wrapErr(err)
+	}
+	if err := applyOperations(sourceValue, destValue, ops, migration); err != nil {
+		return wrapErr(err)
 	}
 	return nil
 }
 
-func migrateField(sourceValue reflect.Value, destValue reflect.Value, fieldPath string, migration utils.CustomMigration, sourceConfig reflect.Value, version int) error {
+// migrateField migrates the non struct field [fieldPath] of [destValue] from the same field of [sourceValue]
+// or with its custom migration, that receives [sourceConfig]
+func migrateField(ctx context.Context, sourceValue reflect.Value, destValue reflect.Value, fieldPath string, migration utils.CustomMigrationContext, sourceConfig reflect.Value, version int) error {
 	// get field name from dotted path
 	spl := strings.Split(fieldPath, ".")
 	fieldName := spl[len(spl)-1]
 
 	if f, ok := migration[fieldPath]; ok {
-		newValue := f(sourceConfig.Interface())
-		destField := destValue.FieldByName(fieldName)
-		if destField.IsValid() && destField.CanSet() {
-			destField.Set(reflect.ValueOf(newValue).Convert(destField.Type()))
+		newValue, err := f(ctx, sourceConfig.Interface())
+		if err != nil {
+			return fmt.Errorf("field %s: %w", fieldPath, err)
 		}
+		return setField(destValue.FieldByName(fieldName), reflect.ValueOf(newValue), fieldPath)
 	} else if fieldName == "Version" {
-		destField := destValue.FieldByName(fieldName)
-		if destField.IsValid() && destField.CanSet() {
-			destField.Set(reflect.ValueOf(version).Convert(destField.Type()))
-		}
-	} else {
+		return setField(destValue.FieldByName(fieldName), reflect.ValueOf(version), fieldPath)
+	} else if sourceValue.Kind() == reflect.Struct { // the source struct may be missing in the old version
 		sourceField := sourceValue.FieldByName(fieldName)
-		if sourceField.IsValid() {
-			destField := destValue.FieldByName(fieldName)
-			if destField.IsValid() && destField.CanSet() {
-				destField.Set(sourceField.Convert(destField.Type()))
-			}
+		destField := destValue.FieldByName(fieldName)
+		if sourceField.IsValid() && destField.CanSet() {
+			return migrateValue(ctx, sourceField, destField, fieldPath, migration, sourceConfig, version)
 		}
 	}
 	return nil
 }
 
-func migrateStruct(sourceValue reflect.Value, destValue reflect.Value, structName string, migration utils.CustomMigration, sourceConfig reflect.Value, version int) error {
+// setField sets [value] converted to the type of [destField], if it exists and can be set
+func setField(destField reflect.Value, value reflect.Value, fieldPath string) error {
+	if !destField.IsValid() || !destField.CanSet() {
+		return nil
+	}
+	converted, err := convert(value, destField.Type(), fieldPath)
+	if err != nil {
+		return err
+	}
+	destField.Set(converted)
+	return nil
+}
+
+// migrateStruct migrates every field of the struct [destValue] from [sourceValue],
+// that may be missing (invalid) in the old version
+func migrateStruct(ctx context.Context, sourceValue reflect.Value, destValue reflect.Value, structName string, migration utils.CustomMigrationContext, sourceConfig reflect.Value, version int) error {
 	wrapErr := func(err error) error {
 		return fmt.Errorf("migrating struct: %w", err)
 	}
@@ -444,18 +526,27 @@ func migrateStruct(sourceValue reflect.Value, destValue reflect.Value, structNam
 	for i := 0; i < destValue.NumField(); i++ {
 		field := destValue.Type().Field(i)
 		fieldName := field.Name
+		fieldPath := fieldName
+		if structName != "" {
+			fieldPath = structName + "." + fieldName
+		}
 
 		// if field is of struct type
 		if field.Type.Kind() == reflect.Struct {
-			sourceStruct := sourceValue.FieldByName(fieldName)
+			// the source struct may be missing in the old version
+			var sourceStruct reflect.Value
+			if sourceValue.Kind() == reflect.Struct {
+				sourceStruct = sourceValue.FieldByName(fieldName)
+			}
+			if sourceStruct.IsValid() && sourceStruct.Kind() != reflect.Struct {
+				sourceStruct = reflect.Indirect(sourceStruct)
+			}
 			destStruct := destValue.FieldByName(fieldName)
-			err := migrateStruct(sourceStruct, destStruct, structName+"."+fieldName, migration, sourceConfig, version)
-			if err != nil {
+			if err := migrateStruct(ctx, sourceStruct, destStruct, fieldPath, migration, sourceConfig, version); err != nil {
 				return wrapErr(err)
 			}
 		} else { // if field is not of type struct
-			err := migrateField(sourceValue, destValue, structName+"."+fieldName, migration, sourceConfig, version)
-			if err != nil {
+			if err := migrateField(ctx, sourceValue, destValue, fieldPath, migration, sourceConfig, version); err != nil {
 				return wrapErr(err)
 			}
 		}
@@ -463,28 +554,289 @@ func migrateStruct(sourceValue reflect.Value, destValue reflect.Value, structNam
 	return nil
 }
 
-func findByVersion(version int) (*utils.ConfigVersion, int) {
-	for i, cv := range configVersions {
-		if cv.Config.V() == version {
+// migrateValue migrates [sourceValue] to [destValue] with the dotted path [path]: slices, arrays, maps and pointers
+// are migrated element by element so that the structs they contain can change between versions.
+// The elements have path [path]+"[]" and their custom migrations receive the source element instead of [sourceConfig]
+func migrateValue(ctx context.Context, sourceValue reflect.Value, destValue reflect.Value, path string, migration utils.CustomMigrationContext, sourceConfig reflect.Value, version int) error {
+	destType := destValue.Type()
+	switch {
+	case destType.Kind() == reflect.Ptr && (sourceValue.Kind() == reflect.Ptr || sourceValue.Kind() == destType.Elem().Kind()):
+		if sourceValue.Kind() == reflect.Ptr {
+			if sourceValue.IsNil() {
+				destValue.Set(reflect.Zero(destType))
+				return nil
+			}
+			sourceValue = sourceValue.Elem()
+		}
+		elem := reflect.New(destType.Elem())
+		if err := migrateValue(ctx, sourceValue, elem.Elem(), path, migration, sourceConfig, version); err != nil {
+			return err
+		}
+		destValue.Set(elem)
+		return nil
+
+	case destType.Kind() == reflect.Struct && sourceValue.Kind() == reflect.Ptr:
+		if sourceValue.IsNil() {
+			destValue.Set(reflect.Zero(destType))
+			return nil
+		}
+		return migrateValue(ctx, sourceValue.Elem(), destValue, path, migration, sourceConfig, version)
+
+	case destType.Kind() == reflect.Struct && sourceValue.Kind() == reflect.Struct:
+		return migrateStruct(ctx, sourceValue, destValue, path, migration, sourceConfig, version)
+
+	case (destType.Kind() == reflect.Slice || destType.Kind() == reflect.Array) &&
+		(sourceValue.Kind() == reflect.Slice || sourceValue.Kind() == reflect.Array):
+		if sourceValue.Kind() == reflect.Slice && sourceValue.IsNil() {
+			destValue.Set(reflect.Zero(destType))
+			return nil
+		}
+		n := sourceValue.Len()
+		elems := destValue
+		if destType.Kind() == reflect.Slice {
+			elems = reflect.MakeSlice(destType, n, n)
+		} else if n > destValue.Len() {
+			return &ConversionError{Source: sourceValue.Type(), Destination: destType, Path: path,
+				Reason: fmt.Sprintf("%d elements don't fit in %d", n, destValue.Len())}
+		}
+		for i := 0; i < n; i++ {
+			if err := migrateElem(ctx, sourceValue.Index(i), elems.Index(i), path+"[]", migration, version); err != nil {
+				return err
+			}
+		}
+		destValue.Set(elems)
+		return nil
+
+	case destType.Kind() == reflect.Map && sourceValue.Kind() == reflect.Map:
+		if sourceValue.IsNil() {
+			destValue.Set(reflect.Zero(destType))
+			return nil
+		}
+		if !convertible(sourceValue.Type().Key(), destType.Key()) {
+			return &ConversionError{Source: sourceValue.Type(), Destination: destType, Path: path}
+		}
+		elems := reflect.MakeMapWithSize(destType, sourceValue.Len())
+		iter := sourceValue.MapRange()
+		for iter.Next() {
+			key := iter.Key().Convert(destType.Key())
+			elem := reflect.New(destType.Elem()).Elem()
+			if err := migrateElem(ctx, iter.Value(), elem, path+"[]", migration, version); err != nil {
+				return err
+			}
+			elems.SetMapIndex(key, elem)
+		}
+		destValue.Set(elems)
+		return nil
+	}
+
+	converted, err := convert(sourceValue, destType, path)
+	if err != nil {
+		return err
+	}
+	destValue.Set(converted)
+	return nil
+}
+
+// migrateElem migrates the element [sourceValue] of a slice, array or map to [destValue] with its
+// custom migration, that receives the source element, or as migrateValue
+func migrateElem(ctx context.Context, sourceValue reflect.Value, destValue reflect.Value, path string, migration utils.CustomMigrationContext, version int) error {
+	if f, ok := migration[path]; ok {
+		newValue, err := f(ctx, sourceValue.Interface())
+		if err != nil {
+			return fmt.Errorf("field %s: %w", path, err)
+		}
+		return setField(destValue, reflect.ValueOf(newValue), path)
+	}
+	return migrateValue(ctx, sourceValue, destValue, path, migration, sourceValue, version)
+}
+
+func (s *snapshot) findByVersion(version int) (*utils.ConfigVersion, int) {
+	for i, cv := range s.configVersions {
+		if versionNumber(cv) == version {
 			return &cv, i
 		}
 	}
 	return nil, -1
 }
 
+// versionNumber returns the version of [cv]: the one of its Config or Version if it is retired
+func versionNumber(cv utils.ConfigVersion) int {
+	if cv.Config == nil {
+		return cv.Version
+	}
+	return cv.Config.V()
+}
+
+// migrate applies to [current] the migrations needed to go from the registered version at index [from]
+// to the one at index [to], UP or DOWN depending on their order.
+// [current] is a Config struct (or pointer to it) or the yaml tree of a retired version.
+// [ctx] is passed to custom migrations and hooks and checked before every step
+func (s *snapshot) migrate(ctx context.Context, current interface{}, from, to int) (interface{}, error) {
+	for i := from; i != to; {
+		next := i + 1
+		if to < from {
+			next = i - 1
+		}
+		st := s.step(i, next)
+		vFrom, vTo := versionNumber(s.configVersions[i]), versionNumber(s.configVersions[next])
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("from version %d to %d: %w", vFrom, vTo, err)
+		}
+		s.logger.Debug("migration step", "from", vFrom, "to", vTo)
+
+		// an AfterDown hook can restore any field, a node migration works on the yaml tree
+		if to < from && st.nodeMigration == nil && st.after == nil {
+			if lost := lossyFields(current, s.configVersions[next], s.configVersions[i].Ops, st.migration); len(lost) > 0 {
+				if s.onLoss != nil {
+					s.onLoss(vFrom, vTo, lost)
+				}
+				if s.strictDown {
+					return nil, &LossyMigrationError{FromVersion: vFrom, ToVersion: vTo, Fields: lost}
+				}
+				s.logger.Info("lossy migration step", "from", vFrom, "to", vTo, "fields", lost)
+			}
+		}
+
+		var err error
+		current, err = migrateStep(ctx, current, s.configVersions[next], st)
+		if err != nil {
+			var convErr *ConversionError
+			if errors.As(err, &convErr) {
+				convErr.FromVersion, convErr.ToVersion = vFrom, vTo
+			}
+			return nil, fmt.Errorf("from version %d to %d: %w", vFrom, vTo, err)
+		}
+		i = next
+	}
+	return current, nil
+}
+
+// step contains what is applied to migrate between two consecutive versions
+type step struct {
+	migration     utils.CustomMigrationContext
+	ops           []utils.Operation
+	nodeMigration utils.NodeMigration
+	before, after utils.Hook
+}
+
+// step returns the migration from the registered version at index [from] to the consecutive one at index [to]:
+// migrating up the UP migrations of [to], migrating down the DOWN migrations of [from]
+func (s *snapshot) step(from, to int) step {
+	if to > from {
+		cv := s.configVersions[to]
+		return step{migration: cv.UpMigrations(), ops: cv.Ops, nodeMigration: cv.NodeUp, before: cv.BeforeUp, after: cv.AfterUp}
+	}
+	cv := s.configVersions[from]
+	return step{migration: cv.DownMigrations(), ops: downOperations(cv.Ops, configType(s.configVersions[to])), nodeMigration: cv.NodeDown, before: cv.BeforeDown, after: cv.AfterDown}
+}
+
+// lossyFields returns the fields whose values are lost migrating [current] down to [target]
+// with the inverse of the UP operations [ops], see lostFields, except the ones set by a custom [migration]
+func lossyFields(current interface{}, target utils.ConfigVersion, ops []utils.Operation, migration utils.CustomMigrationContext) []string {
+	sourceValue := reflect.Indirect(reflect.ValueOf(current))
+	if target.Config == nil || sourceValue.Kind() != reflect.Struct {
+		return nil
+	}
+	var lost []string
+	for _, path := range lostFields(sourceValue, reflect.TypeOf(target.Config), ops) {
+		if _, ok := migration[path]; !ok {
+			lost = append(lost, path)
+		}
+	}
+	return lost
+}
+
+// migrateStep migrates [current] to the version [target] with the custom migrations, the operations and the hooks
+// of [st] or, if set, with its node migration applied to the yaml tree of [current]
+func migrateStep(ctx context.Context, current interface{}, target utils.ConfigVersion, st step) (interface{}, error) {
+	migration, ops, nodeMigration := st.migration, st.ops, st.nodeMigration
+	tree, isTree := current.(map[string]interface{})
+	if nodeMigration == nil && !isTree {
+		if target.Config == nil {
+			return nil, errors.New("a node migration is needed to migrate to a retired version")
+		}
+		next := reflect.New(reflect.TypeOf(target.Config)).Interface()
+		// hooks receive the struct as the custom migrations, current is a pointer after the first step
+		source := reflect.Indirect(reflect.ValueOf(current)).Interface()
+		if st.before != nil {
+			if err := st.before(ctx, source, next); err != nil {
+				return nil, fmt.Errorf("before hook: %w", err)
+			}
+		}
+		if err := MigrateOneContext(ctx, current, next, migration, ops...); err != nil {
+			return nil, err
+		}
+		if st.after != nil {
+			if err := st.after(ctx, source, next); err != nil {
+				return nil, fmt.Errorf("after hook: %w", err)
+			}
+		}
+		return next, nil
+	}
+
+	if st.before != nil || st.after != nil {
+		return nil, errors.New("hooks need the Config structs of both versions and can't be used with node migrations")
+	}
+	if nodeMigration == nil && (len(migration) > 0 || len(ops) > 0) {
+		return nil, errors.New("custom migrations and operations need the Config struct of the version before, use a node migration")
+	}
+	if !isTree {
+		var err error
+		if tree, err = toTree(current); err != nil {
+			return nil, err
+		}
+	}
+	if nodeMigration != nil {
+		if err := nodeMigration(tree); err != nil {
+			return nil, fmt.Errorf("node migration: %w", err)
+		}
+	}
+	tree["version"] = versionNumber(target)
+	if target.Config == nil {
+		return tree, nil
+	}
+	return fromTree(tree, target.Config)
+}
+
 // MigrateUp applies the UP migrations form yaml [source] to [destination] returning the
-// fullfilled [destination] version (Config interface)
+// fullfilled [destination] version (Config interface) using the default registry
 func MigrateUp(source interface{}, destination interface{}) (utils.Config, error) {
+	return defaultRegistry.MigrateUp(source, destination)
+}
+
+// MigrateUp applies the UP migrations form yaml [source] to [destination] returning the
+// fullfilled [destination] version (Config interface)
+func (r *Registry) MigrateUp(source interface{}, destination interface{}) (utils.Config, error) {
+	return r.snapshot().migrateUp(context.Background(), source, destination)
+}
+
+// MigrateUpContext is like MigrateUp using the default registry, see Registry.MigrateUpContext
+func MigrateUpContext(ctx context.Context, source interface{}, destination interface{}) (utils.Config, error) {
+	return defaultRegistry.MigrateUpContext(ctx, source, destination)
+}
+
+// MigrateUpContext is like MigrateUp passing [ctx] to the custom migrations and the hooks,
+// the migration stops before the next step when [ctx] is done
+func (r *Registry) MigrateUpContext(ctx context.Context, source interface{}, destination interface{}) (utils.Config, error) {
+	return r.snapshot().migrateUp(ctx, source, destination)
+}
+
+// migrateUp applies the UP migrations form yaml [source] to [destination] returning the
+// fullfilled [destination] version (Config interface)
+func (s *snapshot) migrateUp(ctx context.Context, source interface{}, destination interface{}) (utils.Config, error) {
 	wrapErr := func(err error) error {
 		return fmt.Errorf("migrating up yaml: %w", err)
 	}
 
 	var vStart, vFinish int
-	s, ok := source.(utils.Config)
+	src, ok := source.(utils.Config)
 	if !ok {
 		return nil, wrapErr(errors.New("source doesn't implement Config interface"))
 	}
-	_, vStart = findByVersion(s.V())
+	if isNil(src) {
+		return nil, wrapErr(errors.New("source is nil"))
+	}
+	_, vStart = s.findByVersion(src.V())
 	if vStart < 0 {
 		return nil, wrapErr(errors.New("version not find in source"))
 	}
@@ -493,35 +845,62 @@ func MigrateUp(source interface{}, destination interface{}) (utils.Config, error
 	if !ok {
 		return nil, wrapErr(errors.New("destination doesn't implement Config interface"))
 	}
-	_, vFinish = findByVersion(d.V())
+	if isNil(d) {
+		return nil, wrapErr(errors.New("destination is nil"))
+	}
+	_, vFinish = s.findByVersion(d.V())
 	if vFinish < 0 {
 		return nil, wrapErr(errors.New("version not find in destination"))
 	}
 
-	var current = source
-	for i := vStart; i < vFinish; i++ {
-		next := reflect.New(reflect.TypeOf(configVersions[i+1].Config)).Interface()
-		err := MigrateOne(current, next, configVersions[i+1].Up)
-		if err != nil {
-			return nil, wrapErr(err)
-		}
-		current = next
+	if vStart >= vFinish {
+		return src, nil
+	}
+	current, err := s.migrate(ctx, source, vStart, vFinish)
+	if err != nil {
+		return nil, wrapErr(err)
 	}
 	return current.(utils.Config), nil
 }
 
 // MigrateDown applies the DOWN migrations form yaml [source] to [destination] returning the
-// fullfilled [destination] version (Config interface)
+// fullfilled [destination] version (Config interface) using the default registry
 func MigrateDown(source interface{}, destination interface{}) (utils.Config, error) {
+	return defaultRegistry.MigrateDown(source, destination)
+}
+
+// MigrateDown applies the DOWN migrations form yaml [source] to [destination] returning the
+// fullfilled [destination] version (Config interface)
+func (r *Registry) MigrateDown(source interface{}, destination interface{}) (utils.Config, error) {
+	return r.snapshot().migrateDown(context.Background(), source, destination)
+}
+
+// MigrateDownContext is like MigrateDown using the default registry, see Registry.MigrateDownContext
+func MigrateDownContext(ctx context.Context, source interface{}, destination interface{}) (utils.Config, error) {
+	return defaultRegistry.MigrateDownContext(ctx, source, destination)
+}
+
+// MigrateDownContext is like MigrateDown passing [ctx] to the custom migrations and the hooks,
+// the migration stops before the next step when [ctx] is done
+func (r *Registry) MigrateDownContext(ctx context.Context, source interface{}, destination interface{}) (utils.Config, error) {
+	return r.snapshot().migrateDown(ctx, source, destination)
+}
+
+// migrateDown applies the DOWN migrations form yaml [source] to [destination] returning the
+// fullfilled [destination] version (Config interface)
+func (s *snapshot) migrateDown(ctx context.Context, source interface{}, destination interface{}) (utils.Config, error) {
 	wrapErr := func(err error) error {
-		return fmt.Errorf("migrating up yaml: %w", err)
+		return fmt.Errorf("migrating down yaml: %w", err)
 	}
 	var vStart, vFinish int
-	s, ok := source.(utils.Config)
+	src, ok := source.(utils.Config)
 	if !ok {
 		return nil, wrapErr(errors.New("source doesn't implement Config interface"))
 	}
-	_, vStart = findByVersion(s.V())
+	if isNil(src) {
+		return nil, wrapErr(errors.New("source is nil"))
+	}
+	_, vStart = s.findByVersion(src.V())
 	if vStart < 0 {
 		return nil, wrapErr(errors.New("version not find in source"))
 	}
@@ -530,19 +909,73 @@ func MigrateDown(source interface{}, destination interface{}) (utils.Config, err
 	if !ok {
 		return nil, wrapErr(errors.New("destination doesn't implement Config interface"))
 	}
-	_, vFinish = findByVersion(d.V())
+	if isNil(d) {
+		return nil, wrapErr(errors.New("destination is nil"))
+	}
+	_, vFinish = s.findByVersion(d.V())
 	if vFinish < 0 {
 		return nil, wrapErr(errors.New("version not find in destination"))
 	}
 
-	var current = source
-	for i := vStart; i > vFinish; i-- {
-		next := reflect.New(reflect.TypeOf(configVersions[i-1].Config)).Interface()
-		err := MigrateOne(current, next, configVersions[i].Down)
-		if err != nil {
-			return nil, wrapErr(err)
-		}
-		current = next
+	if vStart <= vFinish {
+		return src, nil
+	}
+	current, err := s.migrate(ctx, source, vStart, vFinish)
+	if err != nil {
+		return nil, wrapErr(err)
 	}
 	return current.(utils.Config), nil
 }
+
+// MigrateTo applies the UP or DOWN migrations needed to bring [source] to [targetVersion]
+// using the default registry, see Registry.MigrateTo
+func MigrateTo(source interface{}, targetVersion int) (utils.Config, error) {
+	return defaultRegistry.MigrateTo(source, targetVersion)
+}
+
+// MigrateTo applies the UP or DOWN migrations needed to bring [source] to [targetVersion],
+// the direction is chosen from the order of the registered versions.
+// It always returns a pointer to a newly allocated struct of the target version,
+// also when [source] is already at [targetVersion]
+func (r *Registry) MigrateTo(source interface{}, targetVersion int) (utils.Config, error) {
+	return r.snapshot().migrateTo(source, targetVersion)
+}
+
+func (s *snapshot) migrateTo(source interface{}, targetVersion int) (utils.Config, error) {
+	wrapErr := func(err error) error {
+		return fmt.Errorf("migrating yaml to version %d: %w", targetVersion, err)
+	}
+
+	src, ok := source.(utils.Config)
+	if !ok {
+		return nil, wrapErr(errors.New("source doesn't implement Config interface"))
+	}
+	if isNil(src) {
+		return nil, wrapErr(errors.New("source is nil"))
+	}
+	_, vStart := s.findByVersion(src.V())
+	if vStart < 0 {
+		return nil, wrapErr(fmt.Errorf("source version %d is not registered", src.V()))
+	}
+	target, vFinish := s.findByVersion(targetVersion)
+	if vFinish < 0 {
+		return nil, wrapErr(fmt.Errorf("target version %d is not registered", targetVersion))
+	}
+	if target.Config == nil {
+		return nil, wrapErr(fmt.Errorf("target version %d is retired and has no Config struct", targetVersion))
+	}
+
+	switch {
+	case vStart < vFinish:
+		return s.migrateUp(context.Background(), source, target.Config)
+	case vStart > vFinish:
+		return s.migrateDown(context.Background(), source, target.Config)
+	}
+
+	// same version, return a copy
+	sourceValue := reflect.Indirect(reflect.ValueOf(source))
+	if sourceValue.Type() != reflect.TypeOf(target.Config) {
+		return nil, wrapErr(fmt.Errorf("source of type %v is not %T", sourceValue.Type(), target.Config))
+	}
+	return copyConfig(src), nil
+}
@@ -0,0 +1,124 @@
+package versioningyaml
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// walkFields calls [visit] for every field of the struct type [t] with its dotted path prefixed by [prefix],
+// going into the fields of struct type for which visit returns true.
+// It walks the schema of the fields: slices, arrays, maps and pointers are fields themselves, their
+// elements (migrated one by one, see migrateValue) are not walked. See walkValues for the values`)
+func walkFields(t reflect.Type, prefix string, visit func(path string, field reflect.StructField) bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		path := prefix + field.Name
+		if visit(path, field) && field.Type.Kind() == reflect.Struct {
+			walkFields(field.Type, path+".", visit)
+		}
+	}
+}
+
+// walkValues calls [visit] for every non composite value reachable from [v] with its path [path]
+// and its static type [t], going into the fields of structs, the values of pointers and interfaces and
+// the elements of slices, arrays and maps as migrating does. Fields are joined with ".", elements
+// are addressed as [index] and [key] (map keys in order). Nil pointers and interfaces are visited as
+// a whole as the pointers back to a value being walked (cycles), empty slices and maps have no values to visit
+func walkValues(v reflect.Value, t reflect.Type, path string, visit func(path string, t reflect.Type, v reflect.Value)) {
+	walkValuesOnce(v, t, path, visit, map[uintptr]bool{})
+}
+
+// walkValuesOnce walks [v] as walkValues does, [walking] are the pointers being walked up the recursion
+func walkValuesOnce(v reflect.Value, t reflect.Type, path string, visit func(path string, t reflect.Type, v reflect.Value), walking map[uintptr]bool) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() || v.Kind() == reflect.Ptr && walking[v.Pointer()] {
+			visit(path, t, v)
+			return
+		}
+		if v.Kind() == reflect.Ptr {
+			walking[v.Pointer()] = true
+			defer delete(walking, v.Pointer())
+		}
+		walkValuesOnce(v.Elem(), v.Elem().Type(), path, visit, walking)
+	case reflect.Struct:
+		if path != "" {
+			path += "."
+		}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" { // unexported
+				continue
+			}
+			walkValuesOnce(v.Field(i), field.Type, path+field.Name, visit, walking)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkValuesOnce(v.Index(i), t.Elem(), fmt.Sprintf("%s[%d]", path, i), visit, walking)
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return lessKey(keys[i], keys[j]) })
+		for _, key := range keys {
+			walkValuesOnce(v.MapIndex(key), t.Elem(), fmt.Sprintf("%s[%v]", path, key.Interface()), visit, walking)
+		}
+	default:
+		visit(path, t, v)
+	}
+}
+
+// lessKey orders the map keys [a] and [b], numerically if they are numbers
+func lessKey(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	}
+	return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+}
+
+// leafFields returns the dotted paths of the non struct fields of the struct type [t] in order, with their types
+func leafFields(t reflect.Type) ([]string, map[string]reflect.Type) {
+	var paths []string
+	types := map[string]reflect.Type{}
+	walkFields(t, "", func(path string, field reflect.StructField) bool {
+		if field.Type.Kind() == reflect.Struct {
+			return true
+		}
+		paths = append(paths, path)
+		types[path] = field.Type
+		return false
+	})
+	return paths, types
+}
+
+// yamlPath converts the dotted Go [path] of a field of the struct type [t] (as the CustomMigration keys)
+// to the dotted path of yaml keys, the path itself if it doesn't resolve
+func yamlPath(t reflect.Type, path string) string {
+	segments := strings.Split(path, ".")
+	keys := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return path
+		}
+		name := strings.TrimRight(segment, "[]")
+		field, ok := t.FieldByName(name)
+		if !ok {
+			return path
+		}
+		keys = append(keys, yamlKey(field)+segment[len(name):])
+		t = field.Type
+	}
+	return strings.Join(keys, ".")
+}
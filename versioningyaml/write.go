@@ -0,0 +1,124 @@
+package versioningyaml
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// BackupMode tells if and how a copy of a config file is kept before replacing it
+type BackupMode int
+
+const (
+	// NoBackup doesn't keep any copy
+	NoBackup BackupMode = iota
+	// Backup keeps a copy of the replaced file in [path].bak
+	Backup
+	// VersionedBackup keeps a copy of the replaced file in [path].v#.bak where # is its version,
+	// so backups of different versions don't overwrite each other
+	VersionedBackup
+)
+
+// WriteOptions configures how config files are written.
+// Files are always written to a temporary file in the same directory and then renamed,
+// so a crash while writing never leaves a partially written config
+type WriteOptions struct {
+	// Perm is the mode of a newly created file (default 0644), existing files keep their mode
+	Perm fs.FileMode
+	// Sync flushes the file and its directory to disk before returning
+	Sync bool
+	// Backup keeps a copy of the file being replaced
+	Backup BackupMode
+}
+
+// writeFile atomically replaces the file [path] with [content]
+func (s *snapshot) writeFile(path string, content []byte, opts WriteOptions) error {
+	perm := opts.Perm
+	if perm == 0 {
+		perm = 0644
+	}
+
+	info, err := os.Stat(path)
+	switch {
+	case err == nil:
+		perm = info.Mode().Perm()
+		if opts.Backup != NoBackup {
+			old, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("error reading file: %w", err)
+			}
+			if err := s.backup(path, old, perm, opts); err != nil {
+				return err
+			}
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("error reading file: %w", err)
+	}
+
+	return replaceFile(path, content, perm, opts.Sync)
+}
+
+// backup writes the [old] content of [path] in its backup file
+func (s *snapshot) backup(path string, old []byte, perm fs.FileMode, opts WriteOptions) error {
+	backupPath := path + ".bak"
+	if opts.Backup == VersionedBackup {
+		version, err := s.getVersion(old)
+		if err != nil {
+			return fmt.Errorf("error creating backup: %w", err)
+		}
+		backupPath = fmt.Sprintf("%s.v%d.bak", path, version)
+	}
+	if err := replaceFile(backupPath, old, perm, opts.Sync); err != nil {
+		return fmt.Errorf("error creating backup: %w", err)
+	}
+	s.logger.Debug("backup written", "path", backupPath)
+	return nil
+}
+
+// replaceFile writes [content] in a temporary file in the directory of [path] and renames it to [path]
+func replaceFile(path string, content []byte, perm fs.FileMode, sync bool) (err error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp*")
+	if err != nil {
+		return fmt.Errorf("error creating file: %w", err)
+	}
+	// remove the temporary file if anything goes wrong
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := tmp.Write(content); err != nil {
+		return fmt.Errorf("error writing YAML to file: %w", err)
+	}
+	if sync {
+		if err := tmp.Sync(); err != nil {
+			return fmt.Errorf("error syncing file: %w", err)
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return fmt.Errorf("error setting file mode: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error replacing file: %w", err)
+	}
+
+	if sync {
+		// persist the rename, directories can't be synced on every platform so errors are ignored
+		if d, err := os.Open(dir); err == nil {
+			d.Sync()
+			d.Close()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+//go:build !test
+
+package versioningyaml
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	versions "github.com/davide-camponogara/versioningyaml/versions_test"
+)
+
+func TestWriteAtomicWithBackup(t *testing.T) {
+	r := NewRegistry(versions.ConfigVersions)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+
+	old, err := os.ReadFile("testdata/configv1.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, old, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, _, err := r.LoadLatest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WriteYamlWithOptions(config, path, WriteOptions{Sync: true, Backup: VersionedBackup}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("expected mode 0600 to be preserved, got %v", info.Mode().Perm())
+	}
+	backup, err := os.ReadFile(path + ".v1.bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(backup) != string(old) {
+		t.Fatalf("unexpected backup content:\n%s", backup)
+	}
+	if _, version, err := r.LoadConfigVersioned(path); err != nil || version != 3 {
+		t.Fatalf("expected version 3, got %d (%v)", version, err)
+	}
+
+	// new files get the default mode and no temporary file is left behind
+	if err := r.WriteYaml(config, filepath.Join(dir, "new.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected config, backup and new file, got %v", entries)
+	}
+}